@@ -0,0 +1,160 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// ReadArticle parses an article in RFC 5536 format from r.
+//
+// Header order (by first appearance) and repeated headers are
+// preserved, and folded header lines are unfolded in Header.  The
+// header block is also kept verbatim, with CRLF line endings, in
+// RawHeader.  The body is read completely so that Bytes and Lines can
+// be computed.
+func ReadArticle(r io.Reader) (*Article, error) {
+	br := bufio.NewReader(r)
+	a := &Article{Header: make(textproto.MIMEHeader)}
+	raw := &bytes.Buffer{}
+
+	lastKey := ""
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				// Header only, as returned by HEAD.
+				break
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		raw.WriteString(line + "\r\n")
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if lastKey == "" {
+				return nil, textproto.ProtocolError(
+					"malformed header continuation: " + line)
+			}
+			vals := a.Header[lastKey]
+			vals[len(vals)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, textproto.ProtocolError("malformed header line: " + line)
+		}
+		key := strings.TrimSpace(line[:i])
+		lastKey = textproto.CanonicalMIMEHeaderKey(key)
+		if _, seen := a.Header[lastKey]; !seen {
+			a.order = append(a.order, key)
+		}
+		a.Header[lastKey] = append(a.Header[lastKey],
+			strings.TrimSpace(line[i+1:]))
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	a.RawHeader = raw.Bytes()
+
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	a.Body = bytes.NewReader(body)
+	a.Bytes = len(body)
+	a.Lines = bytes.Count(body, []byte{'\n'})
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		a.Lines++
+	}
+
+	return a, nil
+}
+
+// headerKeys returns the header names in the order they should be
+// written: the order they were read in, followed by any remaining
+// headers sorted by name.
+func (a *Article) headerKeys() []string {
+	rv := make([]string, 0, len(a.Header))
+	seen := make(map[string]bool, len(a.Header))
+	for _, k := range a.order {
+		ck := textproto.CanonicalMIMEHeaderKey(k)
+		if _, ok := a.Header[ck]; ok && !seen[ck] {
+			seen[ck] = true
+			rv = append(rv, k)
+		}
+	}
+	var rest []string
+	for k := range a.Header {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(rv, rest...)
+}
+
+// AddHeader adds a header to the article, keeping Header and
+// RawHeader in sync.
+func (a *Article) AddHeader(key, value string) {
+	if a.Header == nil {
+		a.Header = make(textproto.MIMEHeader)
+	}
+	ck := textproto.CanonicalMIMEHeaderKey(key)
+	if _, seen := a.Header[ck]; !seen {
+		a.order = append(a.order, key)
+	}
+	a.Header[ck] = append(a.Header[ck], value)
+	if a.RawHeader != nil {
+		a.RawHeader = append(a.RawHeader, key+": "+value+"\r\n"...)
+	}
+}
+
+// WriteHeader writes the article's headers to w in wire format.
+//
+// RawHeader is written as is when present.  Otherwise headers are
+// written in the order they were read or added, followed by any
+// others sorted by name, so output is stable across calls.
+func (a *Article) WriteHeader(w io.Writer) (int64, error) {
+	if a.RawHeader != nil {
+		n, err := w.Write(a.RawHeader)
+		return int64(n), err
+	}
+	var written int64
+	for _, k := range a.headerKeys() {
+		for _, v := range a.Header[textproto.CanonicalMIMEHeaderKey(k)] {
+			n, err := io.WriteString(w, k+": "+v+"\r\n")
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// WriteTo writes the complete article, headers and body, to w.
+//
+// The body is consumed in the process.
+func (a *Article) WriteTo(w io.Writer) (int64, error) {
+	written, err := a.WriteHeader(w)
+	if err != nil {
+		return written, err
+	}
+	n, err := io.WriteString(w, "\r\n")
+	written += int64(n)
+	if err != nil || a.Body == nil {
+		return written, err
+	}
+	n64, err := io.Copy(w, a.Body)
+	return written + n64, err
+}
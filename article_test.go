@@ -0,0 +1,106 @@
+package nntp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+const sampleArticle = "Path: a!b\r\n" +
+	"From: <nobody@example.com>\r\n" +
+	"Received: from one\r\n" +
+	"Subject: a long\r\n" +
+	"\tsubject\r\n" +
+	"Received: from two\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"\r\n" +
+	"line one\n" +
+	"line two"
+
+func TestReadArticle(t *testing.T) {
+	a, err := ReadArticle(strings.NewReader(sampleArticle))
+	if err != nil {
+		t.Fatalf("Error reading article: %v", err)
+	}
+	if got := a.Header.Get("Subject"); got != "a long subject" {
+		t.Errorf("Expected unfolded subject, got %q", got)
+	}
+	if got := a.Header["Received"]; len(got) != 2 || got[1] != "from two" {
+		t.Errorf("Expected both Received headers, got %q", got)
+	}
+	if a.MessageID() != "<1@example.com>" {
+		t.Errorf("Expected message-id, got %q", a.MessageID())
+	}
+	if a.Bytes != 17 || a.Lines != 2 {
+		t.Errorf("Expected 17 bytes/2 lines, got %v/%v", a.Bytes, a.Lines)
+	}
+	body, _ := io.ReadAll(a.Body)
+	if string(body) != "line one\nline two" {
+		t.Errorf("Unexpected body: %q", body)
+	}
+}
+
+func TestArticleWriteTo(t *testing.T) {
+	a, err := ReadArticle(strings.NewReader(sampleArticle))
+	if err != nil {
+		t.Fatalf("Error reading article: %v", err)
+	}
+	a.AddHeader("X-Added", "yes")
+	buf := &bytes.Buffer{}
+	n, err := a.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Error writing article: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Reported %v bytes, wrote %v", n, buf.Len())
+	}
+	exp := strings.Replace(sampleArticle, "\r\n\r\n",
+		"\r\nX-Added: yes\r\n\r\n", 1)
+	if buf.String() != exp {
+		t.Errorf("Expected:\n%q\ngot:\n%q", exp, buf.String())
+	}
+}
+
+func TestArticleWriteHeaderOrder(t *testing.T) {
+	a, err := ReadArticle(strings.NewReader(sampleArticle))
+	if err != nil {
+		t.Fatalf("Error reading article: %v", err)
+	}
+	a.RawHeader = nil
+	a.Header.Set("X-Added", "yes")
+	a.Header.Set("Approved", "yes")
+	buf := &bytes.Buffer{}
+	if _, err := a.WriteHeader(buf); err != nil {
+		t.Fatalf("Error writing headers: %v", err)
+	}
+	exp := "Path: a!b\r\n" +
+		"From: <nobody@example.com>\r\n" +
+		"Received: from one\r\n" +
+		"Received: from two\r\n" +
+		"Subject: a long subject\r\n" +
+		"Message-ID: <1@example.com>\r\n" +
+		"Approved: yes\r\n" +
+		"X-Added: yes\r\n"
+	if buf.String() != exp {
+		t.Errorf("Expected:\n%q\ngot:\n%q", exp, buf.String())
+	}
+}
+
+func TestReadArticleHeadOnly(t *testing.T) {
+	a, err := ReadArticle(strings.NewReader("Subject: x\nFrom: y"))
+	if err != nil {
+		t.Fatalf("Error reading headers: %v", err)
+	}
+	if a.Header.Get("From") != "y" || a.Bytes != 0 {
+		t.Errorf("Unexpected article: %#v", a)
+	}
+}
+
+func TestReadArticleMalformed(t *testing.T) {
+	for _, in := range []string{" folded: first\n\nbody", "no colon\n\nbody"} {
+		if _, err := ReadArticle(strings.NewReader(in)); err == nil {
+			t.Errorf("Expected error reading %q", in)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+// Package backendtest checks that an nntpserver.Backend behaves as the
+// server expects: group water marks, article numbering, lookups by
+// number and message-id, ranges, cross-posting and error codes.
+//
+// A backend's tests run the suite with a function making fresh
+// instances:
+//
+//	func TestConformance(t *testing.T) {
+//		backendtest.Run(t, func(t *testing.T, groups ...string) nntpserver.Backend {
+//			return newMyBackend(groups...)
+//		})
+//	}
+package backendtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/nntptest"
+	"github.com/dustin/go-nntp/server"
+)
+
+// A Factory makes a new Backend holding the named groups, empty and
+// open for posting.
+type Factory func(t *testing.T, groups ...string) nntpserver.Backend
+
+// The groups each Backend is made with.
+const (
+	groupOne = "backendtest.one"
+	groupTwo = "backendtest.two"
+)
+
+// Run runs the conformance tests as subtests of t, each with a new
+// Backend from newBackend.
+func Run(t *testing.T, newBackend Factory) {
+	tests := []struct {
+		name string
+		f    func(*testing.T, nntpserver.Backend)
+	}{
+		{"ListGroups", testListGroups},
+		{"GetGroup", testGetGroup},
+		{"Numbering", testNumbering},
+		{"GetArticle", testGetArticle},
+		{"GetArticles", testGetArticles},
+		{"Crosspost", testCrosspost},
+		{"Duplicate", testDuplicate},
+		{"UserBackend", testUserBackend},
+		{"CapabilityBackend", testCapabilityBackend},
+		{"Server", testServer},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.f(t, newBackend(t, groupOne, groupTwo))
+		})
+	}
+}
+
+// newArticle makes an article with a new message-id.
+func newArticle(t *testing.T, groups, subject string) *nntp.Article {
+	t.Helper()
+	a, err := nntp.ReadArticle(strings.NewReader(fmt.Sprintf(
+		"Message-Id: %s\r\n"+
+			"From: backendtest@example.com\r\n"+
+			"Newsgroups: %s\r\n"+
+			"Subject: %s\r\n"+
+			"\r\n"+
+			"Body of %s.\r\n",
+		nntp.NewMessageID("backendtest.example.com"), groups, subject, subject)))
+	if err != nil {
+		t.Fatalf("Error making article: %v", err)
+	}
+	return a
+}
+
+// post posts new articles and returns their message-ids.
+func post(t *testing.T, b nntpserver.Backend, groups string, n int) []string {
+	t.Helper()
+	if !b.AllowPost() {
+		t.Fatalf("AllowPost() = false, expected a backend open for posting")
+	}
+	var ids []string
+	for i := 0; i < n; i++ {
+		a := newArticle(t, groups, fmt.Sprintf("test %d", i))
+		if err := b.Post(a); err != nil {
+			t.Fatalf("Error posting %v: %v", a.MessageID(), err)
+		}
+		ids = append(ids, a.MessageID())
+	}
+	return ids
+}
+
+func getGroup(t *testing.T, b nntpserver.Backend, name string) nntp.Group {
+	t.Helper()
+	g, err := b.GetGroup(name)
+	if err != nil {
+		t.Fatalf("Error getting %v: %v", name, err)
+	}
+	if g.Name != name {
+		t.Fatalf("GetGroup(%q) returned group %q", name, g.Name)
+	}
+	return *g
+}
+
+// checkMarks checks a group's count is consistent with its low and
+// high water marks.
+func checkMarks(t *testing.T, g nntp.Group) {
+	t.Helper()
+	switch {
+	case g.Count < 0 || g.Low < 0 || g.High < 0:
+		t.Errorf("%v: negative count or water mark: %+v", g.Name, g)
+	case g.Count == 0:
+		// RFC 3977 prefers High = Low-1, but 0 0 is common.
+		if g.High >= g.Low && g.High != 0 {
+			t.Errorf("%v: empty with water marks %d-%d", g.Name, g.Low, g.High)
+		}
+	case g.Low < 1 || g.Low > g.High || g.Count > g.High-g.Low+1:
+		t.Errorf("%v: count %d doesn't fit water marks %d-%d",
+			g.Name, g.Count, g.Low, g.High)
+	}
+}
+
+// numbered returns the number of each message-id in group, checking
+// that each number returns the right article.
+func numbered(t *testing.T, b nntpserver.Backend, group string) map[string]int64 {
+	t.Helper()
+	g := getGroup(t, b, group)
+	rv := map[string]int64{}
+	arts, err := b.GetArticles(&g, g.Low, g.High)
+	if err != nil {
+		t.Fatalf("Error getting %v %d-%d: %v", group, g.Low, g.High, err)
+	}
+	for _, na := range arts {
+		id := na.Article.MessageID()
+		rv[id] = na.Num
+		a, err := b.GetArticle(&g, strconv.FormatInt(na.Num, 10))
+		if err != nil {
+			t.Errorf("Error getting %v %d: %v", group, na.Num, err)
+		} else if a.MessageID() != id {
+			t.Errorf("%v %d is %v by number, %v in range",
+				group, na.Num, a.MessageID(), id)
+		}
+	}
+	return rv
+}
+
+func testListGroups(t *testing.T, b nntpserver.Backend) {
+	groups, err := b.ListGroups(-1)
+	if err != nil {
+		t.Fatalf("Error listing groups: %v", err)
+	}
+	found := map[string]bool{}
+	for _, g := range groups {
+		found[g.Name] = true
+		checkMarks(t, *g)
+	}
+	for _, name := range []string{groupOne, groupTwo} {
+		if !found[name] {
+			t.Errorf("Group %v not listed", name)
+		}
+	}
+}
+
+func testGetGroup(t *testing.T, b nntpserver.Backend) {
+	g := getGroup(t, b, groupOne)
+	if g.Count != 0 {
+		t.Errorf("Expected new group to be empty, got %+v", g)
+	}
+	checkMarks(t, g)
+
+	if _, err := b.GetGroup("backendtest.missing"); !errors.Is(err, nntp.ErrNoSuchGroup) {
+		t.Errorf("Expected ErrNoSuchGroup for a missing group, got %v", err)
+	}
+}
+
+func testNumbering(t *testing.T, b nntpserver.Backend) {
+	prev := getGroup(t, b, groupOne)
+	for i := 0; i < 3; i++ {
+		id := post(t, b, groupOne, 1)[0]
+		g := getGroup(t, b, groupOne)
+		checkMarks(t, g)
+		if g.Count != prev.Count+1 {
+			t.Errorf("Count went from %d to %d after posting", prev.Count, g.Count)
+		}
+		if g.High <= prev.High {
+			t.Errorf("High went from %d to %d after posting", prev.High, g.High)
+		}
+		a, err := b.GetArticle(&g, strconv.FormatInt(g.High, 10))
+		if err != nil || a.MessageID() != id {
+			t.Errorf("Expected %v at high water mark %d, got %v",
+				id, g.High, err)
+		}
+		prev = g
+	}
+}
+
+func testGetArticle(t *testing.T, b nntpserver.Backend) {
+	posted := newArticle(t, groupOne, "lookup")
+	id := posted.MessageID()
+	if err := b.Post(posted); err != nil {
+		t.Fatalf("Error posting: %v", err)
+	}
+
+	a, err := b.GetArticle(nil, id)
+	if err != nil {
+		t.Fatalf("Error getting %v: %v", id, err)
+	}
+	if a.MessageID() != id || a.Header.Get("Subject") != "lookup" {
+		t.Errorf("Unexpected headers for %v: %v", id, a.Header)
+	}
+	if a.Body == nil {
+		t.Fatalf("No body for %v", id)
+	}
+	body, err := io.ReadAll(a.Body)
+	if err != nil {
+		t.Fatalf("Error reading body of %v: %v", id, err)
+	}
+	if got := strings.ReplaceAll(string(body), "\r\n", "\n"); got != "Body of lookup.\n" {
+		t.Errorf("Unexpected body for %v: %q", id, body)
+	}
+
+	g := getGroup(t, b, groupOne)
+	if a, err := b.GetArticle(&g, id); err != nil || a.MessageID() != id {
+		t.Errorf("Error getting %v with a group selected: %v", id, err)
+	}
+	if _, err := b.GetArticle(nil, "<missing@backendtest.example.com>"); !errors.Is(err, nntp.ErrNoSuchArticle) {
+		t.Errorf("Expected ErrNoSuchArticle for a missing message-id, got %v", err)
+	}
+	missing := strconv.FormatInt(g.High+1, 10)
+	if _, err := b.GetArticle(&g, missing); !errors.Is(err, nntp.ErrInvalidArticleNumber) {
+		t.Errorf("Expected ErrInvalidArticleNumber for number %v, got %v", missing, err)
+	}
+}
+
+func testGetArticles(t *testing.T, b nntpserver.Backend) {
+	ids := post(t, b, groupOne, 3)
+	g := getGroup(t, b, groupOne)
+	nums := numbered(t, b, groupOne)
+	for i, id := range ids {
+		if _, ok := nums[id]; !ok {
+			t.Fatalf("%v missing from %v %d-%d", id, groupOne, g.Low, g.High)
+		}
+		if i > 0 && nums[id] <= nums[ids[i-1]] {
+			t.Errorf("%v numbered %d after %v numbered %d",
+				id, nums[id], ids[i-1], nums[ids[i-1]])
+		}
+	}
+
+	middle := nums[ids[1]]
+	for _, r := range []struct {
+		from, to int64
+		exp      []string
+	}{
+		{g.Low, math.MaxInt64, ids},
+		{middle, middle, ids[1:2]},
+		{middle, g.High, ids[1:]},
+		{g.High + 1, g.High + 10, nil},
+		{g.High, g.Low, nil},
+	} {
+		arts, err := b.GetArticles(&g, r.from, r.to)
+		if err != nil {
+			t.Errorf("Error getting %d-%d: %v", r.from, r.to, err)
+			continue
+		}
+		var got []string
+		for i, na := range arts {
+			got = append(got, na.Article.MessageID())
+			if i > 0 && na.Num <= arts[i-1].Num {
+				t.Errorf("%d-%d: %d returned after %d",
+					r.from, r.to, na.Num, arts[i-1].Num)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(r.exp, " ") {
+			t.Errorf("%d-%d: expected %v, got %v", r.from, r.to, r.exp, got)
+		}
+	}
+}
+
+func testCrosspost(t *testing.T, b nntpserver.Backend) {
+	post(t, b, groupOne, 1)
+	id := post(t, b, groupOne+", "+groupTwo, 1)[0]
+	for _, name := range []string{groupOne, groupTwo} {
+		g := getGroup(t, b, name)
+		checkMarks(t, g)
+		if _, ok := numbered(t, b, name)[id]; !ok {
+			t.Errorf("Cross-posted %v missing from %v", id, name)
+		}
+	}
+	if a, err := b.GetArticle(nil, id); err != nil || a.MessageID() != id {
+		t.Errorf("Error getting cross-posted %v: %v", id, err)
+	}
+
+	unknown := newArticle(t, "backendtest.missing", "nowhere")
+	if err := b.Post(unknown); err == nil {
+		t.Errorf("Expected an error posting only to a missing group")
+	}
+}
+
+func testDuplicate(t *testing.T, b nntpserver.Backend) {
+	a := newArticle(t, groupOne, "duplicate")
+	if err := b.Post(a); err != nil {
+		t.Fatalf("Error posting: %v", err)
+	}
+	before := getGroup(t, b, groupOne)
+
+	dup := newArticle(t, groupOne, "duplicate")
+	dup.Header.Set("Message-Id", a.MessageID())
+	dup.RawHeader = nil
+	err := b.Post(dup)
+	if err == nil {
+		t.Fatalf("Expected an error posting a duplicate message-id")
+	}
+	if !hasCode(err, 435, 437, 441) {
+		t.Errorf("Expected a 435, 437 or 441 *nntp.Error for a duplicate, got %v", err)
+	}
+	if after := getGroup(t, b, groupOne); after.Count != before.Count {
+		t.Errorf("Count went from %d to %d after a rejected duplicate",
+			before.Count, after.Count)
+	}
+}
+
+func testUserBackend(t *testing.T, b nntpserver.Backend) {
+	ub, ok := b.(nntpserver.UserBackend)
+	if !ok {
+		t.Skip("Backend doesn't implement UserBackend")
+	}
+	ids := post(t, b, groupOne, 2)
+	ub2, err := ub.ForUser("backendtest")
+	if err != nil {
+		if !hasCode(err, 481, 502) {
+			t.Fatalf("Expected ForUser to refuse with a 481 or 502 *nntp.Error, got %v", err)
+		}
+		t.Skipf("ForUser refused the test user: %v", err)
+	}
+	if ub2 == nil {
+		t.Fatalf("ForUser returned no Backend and no error")
+	}
+
+	// The user's view is of the same groups and articles.
+	names := func(b nntpserver.Backend) string {
+		groups, err := b.ListGroups(-1)
+		if err != nil {
+			t.Fatalf("Error listing groups: %v", err)
+		}
+		var rv []string
+		for _, g := range groups {
+			rv = append(rv, g.Name)
+		}
+		sort.Strings(rv)
+		return strings.Join(rv, " ")
+	}
+	if got, exp := names(ub2), names(b); got != exp {
+		t.Errorf("Expected the user's backend to list %v, got %v", exp, got)
+	}
+	for _, name := range []string{groupOne, groupTwo} {
+		if got, exp := getGroup(t, ub2, name), getGroup(t, b, name); got != exp {
+			t.Errorf("Expected the user's backend to have %+v, got %+v", exp, got)
+		}
+	}
+	got, exp := numbered(t, ub2, groupOne), numbered(t, b, groupOne)
+	for _, id := range ids {
+		if got[id] != exp[id] {
+			t.Errorf("Expected %v to be %v %d for the user, got %d",
+				id, groupOne, exp[id], got[id])
+		}
+		if a, err := ub2.GetArticle(nil, id); err != nil || a.MessageID() != id {
+			t.Errorf("Error getting %v as the user: %v", id, err)
+		}
+	}
+}
+
+// hasCode reports whether err is an *nntp.Error with one of codes.
+func hasCode(err error, codes ...int) bool {
+	var nerr *nntp.Error
+	if !errors.As(err, &nerr) {
+		return false
+	}
+	for _, c := range codes {
+		if nerr.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+func testCapabilityBackend(t *testing.T, b nntpserver.Backend) {
+	if _, ok := b.(nntpserver.CapabilityBackend); !ok {
+		t.Skip("Backend doesn't implement CapabilityBackend")
+	}
+	caps, err := nntptest.NewServer(t, b).Client(t).Capabilities()
+	if err != nil {
+		t.Fatalf("Error getting capabilities: %v", err)
+	}
+	for i, c := range caps {
+		if strings.TrimSpace(c) == "" || (i > 0 && strings.HasPrefix(c, "VERSION")) {
+			t.Errorf("Bad capability line %q", c)
+		}
+	}
+}
+
+// testServer checks the backend through a server and client.
+func testServer(t *testing.T, b nntpserver.Backend) {
+	c := nntptest.NewServer(t, b).Client(t)
+	a := newArticle(t, groupOne, "served")
+	var text strings.Builder
+	a.WriteTo(&text)
+	if err := c.Post(strings.NewReader(text.String())); err != nil {
+		t.Fatalf("Error posting: %v", err)
+	}
+
+	g, err := c.Group(groupOne)
+	if err != nil {
+		t.Fatalf("Error selecting %v: %v", groupOne, err)
+	}
+	_, id, got, err := c.Article(strconv.FormatInt(g.High, 10))
+	if err != nil || id != a.MessageID() {
+		t.Fatalf("Expected %v at %d, got %v (%v)", a.MessageID(), g.High, id, err)
+	}
+	if body, _ := io.ReadAll(got.Body); string(body) != "Body of served.\n" {
+		t.Errorf("Unexpected body %q", body)
+	}
+	lines, err := c.Over(strconv.FormatInt(g.High, 10))
+	if err != nil || len(lines) != 1 || !strings.Contains(lines[0], "\tserved\t") {
+		t.Errorf("Unexpected overview %q (%v)", lines, err)
+	}
+}
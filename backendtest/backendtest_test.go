@@ -0,0 +1,43 @@
+package backendtest
+
+import (
+	"testing"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/nntptest"
+	"github.com/dustin/go-nntp/server"
+)
+
+func newMemoryBackend(t *testing.T, groups ...string) nntpserver.Backend {
+	b := nntptest.NewBackend()
+	for _, g := range groups {
+		b.AddGroup(g, "Test group", nntp.PostingPermitted)
+	}
+	return b
+}
+
+func TestMemoryBackend(t *testing.T) {
+	Run(t, newMemoryBackend)
+}
+
+// userBackend gives every user the same view, or refuses them all.
+type userBackend struct {
+	*nntptest.Backend
+	refuse bool
+}
+
+func (b userBackend) ForUser(user string) (nntpserver.Backend, error) {
+	if b.refuse {
+		return nil, nntpserver.ErrAuthRejected
+	}
+	return b.Backend, nil
+}
+
+func TestUserBackend(t *testing.T) {
+	for name, refuse := range map[string]bool{"Same": false, "Refused": true} {
+		t.Run(name, func(t *testing.T) {
+			b := newMemoryBackend(t, groupOne, groupTwo).(*nntptest.Backend)
+			testUserBackend(t, userBackend{b, refuse})
+		})
+	}
+}
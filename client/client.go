@@ -1,39 +1,86 @@
 // Package nntpclient provides an NNTP Client.
+//
+// Every method that talks to the server has a ...Context variant.  The
+// context's deadline is applied to the underlying connection, and
+// cancelling the context interrupts a command in progress.  Since the
+// state of the protocol stream is unknown after an interrupted
+// command, the client is unusable from then on and every method
+// returns ErrConnBroken.
 package nntpclient
 
 import (
+	"bufio"
+	"compress/zlib"
+	"context"
 	"crypto/tls"
 	"errors"
 	"io"
 	"net"
 	"net/textproto"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-nntp"
 )
 
+// ErrCapabilitiesUnpopulated is returned when checking capabilities
+// before they have been retrieved with Capabilities.
+var ErrCapabilitiesUnpopulated = errors.New("capabilities unpopulated")
+
+// ErrNoSuchCapability is returned when the server doesn't advertise a
+// capability.
+var ErrNoSuchCapability = errors.New("no such capability")
+
+// ErrTLSActive is returned by StartTLS when TLS is already in use.
+var ErrTLSActive = errors.New("TLS already active")
+
+// ErrCompressionActive is returned by Compress when compression is
+// already in use, and by StartTLS after compression, since TLS must be
+// negotiated first.
+var ErrCompressionActive = errors.New("compression already active")
+
+// ErrConnBroken is returned once a command has been interrupted by
+// its context, leaving the connection in an unknown state.
+var ErrConnBroken = errors.New("connection unusable after interrupted command")
+
 // Client is an NNTP client.
 type Client struct {
-	conn   *textproto.Conn
-	netconn net.Conn
-	tls bool
-	Banner string
+	conn         *textproto.Conn
+	netconn      net.Conn
+	tls          bool
+	Banner       string
 	capabilities []string
+	// The currently selected group.
+	group string
+	// Set once COMPRESS DEFLATE is active.
+	compressed bool
+	// Set once XFEATURE COMPRESS GZIP is active.
+	gzipOverview bool
+	// Set when a command was interrupted.
+	broken bool
 }
 
 // New connects a client to an NNTP server.
 func New(network, addr string) (*Client, error) {
-	netconn, err := net.Dial(network, addr)
+	return DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects a client to an NNTP server.  The context
+// covers both connecting and reading the server's greeting.
+func DialContext(ctx context.Context, network, addr string) (*Client, error) {
+	var d net.Dialer
+	netconn, err := d.DialContext(ctx, network, addr)
 	if err != nil {
 		return nil, err
 	}
-	return connect(netconn)
+	return connect(ctx, netconn)
 }
 
 // NewConn wraps an existing connection, for example one opened with tls.Dial
 func NewConn(netconn net.Conn) (*Client, error) {
-	client, err := connect(netconn)
+	client, err := connect(context.Background(), netconn)
 	if err != nil {
 		return nil, err
 	}
@@ -45,11 +92,20 @@ func NewConn(netconn net.Conn) (*Client, error) {
 
 // NewTLS connects to an NNTP server over a dedicated TLS port like 563
 func NewTLS(network, addr string, config *tls.Config) (*Client, error) {
-	netconn, err := tls.Dial(network, addr, config)
+	return DialTLSContext(context.Background(), network, addr, config)
+}
+
+// DialTLSContext connects to an NNTP server over a dedicated TLS port
+// like 563.
+func DialTLSContext(ctx context.Context, network, addr string,
+	config *tls.Config) (*Client, error) {
+
+	d := tls.Dialer{Config: config}
+	netconn, err := d.DialContext(ctx, network, addr)
 	if err != nil {
 		return nil, err
 	}
-	client, err := connect(netconn)
+	client, err := connect(ctx, netconn)
 	if err != nil {
 		return nil, err
 	}
@@ -57,18 +113,80 @@ func NewTLS(network, addr string, config *tls.Config) (*Client, error) {
 	return client, nil
 }
 
-func connect(netconn net.Conn) (*Client, error) {
-	conn := textproto.NewConn(netconn)
-	_, msg, err := conn.ReadCodeLine(200)
+func connect(ctx context.Context, netconn net.Conn) (*Client, error) {
+	c := &Client{
+		conn:    textproto.NewConn(netconn),
+		netconn: netconn,
+	}
+	err := c.do(ctx, func() error {
+		_, msg, err := c.readCodeLine(2)
+		c.Banner = msg
+		return err
+	})
 	if err != nil {
+		c.conn.Close()
 		return nil, err
 	}
+	return c, nil
+}
 
-	return &Client{
-		conn:   conn,
-		netconn: netconn,
-		Banner: msg,
-	}, nil
+// do runs f with the context's deadline set on the connection,
+// interrupting it if the context is cancelled.
+func (c *Client) do(ctx context.Context, f func() error) error {
+	if c.broken {
+		return ErrConnBroken
+	}
+	if ctx.Done() == nil {
+		return f()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		c.netconn.SetDeadline(dl)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			// Unblock any pending read or write.
+			c.netconn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	err := f()
+	close(stop)
+	<-done
+
+	if _, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) {
+		// The connection's deadline may have passed a moment before
+		// the context's, which is what callers should see.
+		<-ctx.Done()
+	}
+	if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		c.broken = true
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	c.netconn.SetDeadline(time.Time{})
+	return err
+}
+
+// readCodeLine reads a response line, converting error responses to
+// *nntp.Error so they can be compared to the nntp package's errors
+// with errors.Is.
+func (c *Client) readCodeLine(expectCode int) (int, string, error) {
+	code, msg, err := c.conn.ReadCodeLine(expectCode)
+	if te, ok := err.(*textproto.Error); ok {
+		err = &nntp.Error{Code: te.Code, Msg: te.Msg}
+	}
+	return code, msg, err
 }
 
 // Close this client.
@@ -78,20 +196,21 @@ func (c *Client) Close() error {
 
 // Authenticate against an NNTP server using authinfo user/pass
 func (c *Client) Authenticate(user, pass string) (msg string, err error) {
-	err = c.conn.PrintfLine("authinfo user %s", user)
-	if err != nil {
-		return
-	}
-	_, _, err = c.conn.ReadCodeLine(381)
-	if err != nil {
-		return
-	}
+	return c.AuthenticateContext(context.Background(), user, pass)
+}
 
-	err = c.conn.PrintfLine("authinfo pass %s", pass)
-	if err != nil {
-		return
-	}
-	_, msg, err = c.conn.ReadCodeLine(281)
+// AuthenticateContext is Authenticate with a context.
+func (c *Client) AuthenticateContext(ctx context.Context,
+	user, pass string) (msg string, err error) {
+
+	err = c.do(ctx, func() error {
+		_, _, err := c.command("authinfo user "+user, 381)
+		if err != nil {
+			return err
+		}
+		_, msg, err = c.command("authinfo pass "+pass, 281)
+		return err
+	})
 	return
 }
 
@@ -106,19 +225,22 @@ func parsePosting(p string) nntp.PostingStatus {
 }
 
 // List groups
-func (c *Client) List(sub string) (rv []nntp.Group, err error) {
-	_, _, err = c.Command("LIST "+sub, 215)
-	if err != nil {
-		return
-	}
-	var groupLines []string
-	groupLines, err = c.conn.ReadDotLines()
+func (c *Client) List(sub string) ([]nntp.Group, error) {
+	return c.ListContext(context.Background(), sub)
+}
+
+// ListContext is List with a context.
+func (c *Client) ListContext(ctx context.Context, sub string) (rv []nntp.Group, err error) {
+	groupLines, err := c.asLinesContext(ctx, strings.TrimSpace("LIST "+sub), 215)
 	if err != nil {
 		return
 	}
 	rv = make([]nntp.Group, 0, len(groupLines))
 	for _, l := range groupLines {
 		parts := strings.Split(l, " ")
+		if len(parts) < 4 {
+			continue
+		}
 		high, errh := strconv.ParseInt(parts[1], 10, 64)
 		low, errl := strconv.ParseInt(parts[2], 10, 64)
 		if errh == nil && errl == nil {
@@ -134,16 +256,22 @@ func (c *Client) List(sub string) (rv []nntp.Group, err error) {
 }
 
 // Group selects a group.
-func (c *Client) Group(name string) (rv nntp.Group, err error) {
+func (c *Client) Group(name string) (nntp.Group, error) {
+	return c.GroupContext(context.Background(), name)
+}
+
+// GroupContext is Group with a context.
+func (c *Client) GroupContext(ctx context.Context, name string) (rv nntp.Group, err error) {
 	var msg string
-	_, msg, err = c.Command("GROUP "+name, 211)
+	_, msg, err = c.CommandContext(ctx, "GROUP "+name, 211)
 	if err != nil {
 		return
 	}
 	// count first last name
 	parts := strings.Split(msg, " ")
 	if len(parts) != 4 {
-		err = errors.New("Don't know how to parse result: " + msg)
+		err = textproto.ProtocolError("Don't know how to parse result: " + msg)
+		return
 	}
 	rv.Count, err = strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
@@ -158,48 +286,174 @@ func (c *Client) Group(name string) (rv nntp.Group, err error) {
 		return
 	}
 	rv.Name = parts[3]
+	c.group = rv.Name
 
 	return
 }
 
-// Article grabs an article
-func (c *Client) Article(specifier string) (int64, string, io.Reader, error) {
-	err := c.conn.PrintfLine("ARTICLE %s", specifier)
+// ModeReader switches a mode-switching server to reader mode.  It
+// reports whether posting is allowed.
+func (c *Client) ModeReader() (bool, error) {
+	return c.ModeReaderContext(context.Background())
+}
+
+// ModeReaderContext is ModeReader with a context.
+func (c *Client) ModeReaderContext(ctx context.Context) (bool, error) {
+	code, _, err := c.CommandContext(ctx, "MODE READER", 2)
 	if err != nil {
-		return 0, "", nil, err
+		return false, err
 	}
-	return c.articleish(220)
+	return code == 200, nil
 }
 
-// Head gets the headers for an article
-func (c *Client) Head(specifier string) (int64, string, io.Reader, error) {
-	err := c.conn.PrintfLine("HEAD %s", specifier)
+// CurrentGroup returns the name of the group last selected with Group.
+func (c *Client) CurrentGroup() string {
+	return c.group
+}
+
+// Date returns the server's current time.
+func (c *Client) Date() (time.Time, error) {
+	return c.DateContext(context.Background())
+}
+
+// DateContext is Date with a context.
+func (c *Client) DateContext(ctx context.Context) (time.Time, error) {
+	_, msg, err := c.CommandContext(ctx, "DATE", 111)
 	if err != nil {
-		return 0, "", nil, err
+		return time.Time{}, err
 	}
-	return c.articleish(221)
+	return time.Parse("20060102150405", strings.TrimSpace(msg))
+}
+
+// Article grabs an article
+func (c *Client) Article(specifier string) (int64, string, *nntp.Article, error) {
+	return c.ArticleContext(context.Background(), specifier)
+}
+
+// ArticleContext is Article with a context.
+func (c *Client) ArticleContext(ctx context.Context,
+	specifier string) (int64, string, *nntp.Article, error) {
+
+	return c.readArticle(ctx, "ARTICLE "+specifier, 220)
+}
+
+// Head gets the headers for an article
+//
+// The returned article has an empty body.
+func (c *Client) Head(specifier string) (int64, string, *nntp.Article, error) {
+	return c.HeadContext(context.Background(), specifier)
+}
+
+// HeadContext is Head with a context.
+func (c *Client) HeadContext(ctx context.Context,
+	specifier string) (int64, string, *nntp.Article, error) {
+
+	return c.readArticle(ctx, "HEAD "+specifier, 221)
 }
 
 // Body gets the body of an article
+//
+// The body is streamed from the connection, so it should be read to
+// the end before the next command.  Any part left unread is skipped
+// when the next command is sent.
 func (c *Client) Body(specifier string) (int64, string, io.Reader, error) {
-	err := c.conn.PrintfLine("BODY %s", specifier)
+	return c.BodyContext(context.Background(), specifier)
+}
+
+// BodyContext is Body with a context.  The context also covers reading
+// the body: if it expires or is cancelled before the end of the body,
+// or the read fails part way, the client is unusable from then on.
+func (c *Client) BodyContext(ctx context.Context,
+	specifier string) (n int64, id string, body io.Reader, err error) {
+
+	var r io.Reader
+	err = c.do(ctx, func() error {
+		n, id, r, err = c.articleish("BODY "+specifier, 222)
+		return err
+	})
 	if err != nil {
 		return 0, "", nil, err
 	}
-	return c.articleish(222)
+	return n, id, &bodyReader{c: c, ctx: ctx, r: r}, nil
 }
 
-func (c *Client) articleish(expected int) (int64, string, io.Reader, error) {
-	_, msg, err := c.conn.ReadCodeLine(expected)
+// bodyReader streams an article body, applying the context of the
+// command to each read.
+type bodyReader struct {
+	c   *Client
+	ctx context.Context
+	r   io.Reader
+}
+
+func (b *bodyReader) Read(p []byte) (n int, err error) {
+	err = b.c.do(b.ctx, func() error {
+		n, err = b.r.Read(p)
+		return err
+	})
+	if err != nil && err != io.EOF {
+		// The rest of the body is still on the wire.
+		b.c.broken = true
+	}
+	return n, err
+}
+
+// Stat checks whether an article exists, returning its number and
+// message-id.
+func (c *Client) Stat(specifier string) (int64, string, error) {
+	return c.StatContext(context.Background(), specifier)
+}
+
+// StatContext is Stat with a context.
+func (c *Client) StatContext(ctx context.Context, specifier string) (int64, string, error) {
+	_, msg, err := c.CommandContext(ctx, "STAT "+specifier, 223)
 	if err != nil {
-		return 0, "", nil, err
+		return 0, "", err
+	}
+	return parseArticleResponse(msg)
+}
+
+func parseArticleResponse(msg string) (int64, string, error) {
+	parts := strings.SplitN(msg, " ", 3)
+	if len(parts) < 2 {
+		return 0, "", textproto.ProtocolError("Don't know how to parse result: " + msg)
 	}
-	parts := strings.SplitN(msg, " ", 2)
 	n, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, parts[1], nil
+}
+
+func (c *Client) articleish(cmd string, expected int) (int64, string, io.Reader, error) {
+	_, msg, err := c.command(cmd, expected)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	n, id, err := parseArticleResponse(msg)
 	if err != nil {
 		return 0, "", nil, err
 	}
-	return n, parts[1], c.conn.DotReader(), nil
+	return n, id, c.conn.DotReader(), nil
+}
+
+// readArticle issues a command whose response data block is an
+// article or article headers and parses it.
+func (c *Client) readArticle(ctx context.Context, cmd string,
+	expected int) (n int64, id string, article *nntp.Article, err error) {
+
+	err = c.do(ctx, func() error {
+		var r io.Reader
+		n, id, r, err = c.articleish(cmd, expected)
+		if err != nil {
+			return err
+		}
+		article, err = nntp.ReadArticle(r)
+		return err
+	})
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return
 }
 
 // Post a new article
@@ -207,54 +461,93 @@ func (c *Client) articleish(expected int) (int64, string, io.Reader, error) {
 // The reader should contain the entire article, headers and body in
 // RFC822ish format.
 func (c *Client) Post(r io.Reader) error {
-	err := c.conn.PrintfLine("POST")
-	if err != nil {
-		return err
-	}
-	_, _, err = c.conn.ReadCodeLine(340)
-	if err != nil {
-		return err
-	}
-	w := c.conn.DotWriter()
-	_, err = io.Copy(w, r)
-	if err != nil {
-		// This seems really bad
+	return c.PostContext(context.Background(), r)
+}
+
+// PostContext is Post with a context.
+func (c *Client) PostContext(ctx context.Context, r io.Reader) error {
+	return c.do(ctx, func() error {
+		_, _, err := c.command("POST", 340)
+		if err != nil {
+			return err
+		}
+		w := c.conn.DotWriter()
+		_, err = io.Copy(w, r)
+		if err != nil {
+			// This seems really bad
+			return err
+		}
+		w.Close()
+		_, _, err = c.readCodeLine(240)
 		return err
-	}
-	w.Close()
-	_, _, err = c.conn.ReadCodeLine(240)
-	return err
+	})
 }
 
 // Command sends a low-level command and get a response.
 //
+// Error responses are returned as *nntp.Error values, which may be
+// compared with errors.Is to the errors in the nntp package, such as
+// nntp.ErrNoSuchGroup.
+//
 // This will return an error if the code doesn't match the expectCode
 // prefix.  For example, if you specify "200", the response code MUST
 // be 200 or you'll get an error.  If you specify "2", any code from
 // 200 (inclusive) to 300 (exclusive) will be success.  An expectCode
 // of -1 disables this behavior.
 func (c *Client) Command(cmd string, expectCode int) (int, string, error) {
-	err := c.conn.PrintfLine(cmd)
+	return c.CommandContext(context.Background(), cmd, expectCode)
+}
+
+// CommandContext is Command with a context.
+func (c *Client) CommandContext(ctx context.Context, cmd string,
+	expectCode int) (code int, msg string, err error) {
+
+	err = c.do(ctx, func() error {
+		code, msg, err = c.command(cmd, expectCode)
+		return err
+	})
+	return
+}
+
+// command sends a command and reads the response line.
+func (c *Client) command(cmd string, expectCode int) (int, string, error) {
+	err := c.conn.PrintfLine("%s", cmd)
 	if err != nil {
 		return 0, "", err
 	}
-	return c.conn.ReadCodeLine(expectCode)
+	return c.readCodeLine(expectCode)
 }
 
 // asLines issues a command and returns the response's data block as lines.
 func (c *Client) asLines(cmd string, expectCode int) ([]string, error) {
-	_, _, err := c.Command(cmd, expectCode)
+	_, _, err := c.command(cmd, expectCode)
 	if err != nil {
 		return nil, err
 	}
 	return c.conn.ReadDotLines()
 }
 
+// asLinesContext is asLines with a context.
+func (c *Client) asLinesContext(ctx context.Context, cmd string,
+	expectCode int) (lines []string, err error) {
+
+	err = c.do(ctx, func() error {
+		lines, err = c.asLines(cmd, expectCode)
+		return err
+	})
+	return
+}
+
 // Capabilities retrieves a list of supported capabilities.
 //
 // See https://datatracker.ietf.org/doc/html/rfc3977#section-5.2.2
 func (c *Client) Capabilities() ([]string, error) {
-	caps, err := c.asLines("CAPABILITIES", 101)
+	return c.CapabilitiesContext(context.Background())
+}
+
+// CapabilitiesContext is Capabilities with a context.
+func (c *Client) CapabilitiesContext(ctx context.Context) ([]string, error) {
+	caps, err := c.asLinesContext(ctx, "CAPABILITIES", 101)
 	if err != nil {
 		return nil, err
 	}
@@ -296,11 +589,11 @@ func (c *Client) HasCapabilityArgument(
 	capability, argument string,
 ) (bool, error) {
 	if c.capabilities == nil {
-		return false, errors.New("Capabilities unpopulated")
+		return false, ErrCapabilitiesUnpopulated
 	}
 	capLine := c.GetCapability(capability)
 	if capLine == "" {
-		return false, errors.New("No such capability")
+		return false, ErrNoSuchCapability
 	}
 	argument = strings.ToUpper(argument)
 	for _, capArg := range strings.Fields(capLine)[1:] {
@@ -319,7 +612,12 @@ func (c *Client) HasCapabilityArgument(
 //
 // See https://datatracker.ietf.org/doc/html/rfc3977#section-3.3.2
 func (c *Client) ListOverviewFmt() ([]string, error) {
-	fields, err := c.asLines("LIST OVERVIEW.FMT", 215)
+	return c.ListOverviewFmtContext(context.Background())
+}
+
+// ListOverviewFmtContext is ListOverviewFmt with a context.
+func (c *Client) ListOverviewFmtContext(ctx context.Context) ([]string, error) {
+	fields, err := c.asLinesContext(ctx, "LIST OVERVIEW.FMT", 215)
 	if err != nil {
 		return nil, err
 	}
@@ -327,12 +625,101 @@ func (c *Client) ListOverviewFmt() ([]string, error) {
 }
 
 // Over returns a list of raw overview lines with tab-separated fields.
+//
+// If the server advertised XZVER or XFEATURE-COMPRESS GZIP in the last
+// Capabilities response, the overview is requested compressed and
+// decompressed here.  Neither is used once COMPRESS DEFLATE is active.
 func (c *Client) Over(specifier string) ([]string, error) {
-	lines, err := c.asLines("OVER "+specifier, 224)
+	return c.OverContext(context.Background(), specifier)
+}
+
+// OverContext is Over with a context.
+func (c *Client) OverContext(ctx context.Context, specifier string) (lines []string, err error) {
+	switch {
+	case c.compressed:
+	case c.GetCapability("XZVER") != "":
+		err = c.do(ctx, func() error {
+			lines, err = c.xzver(specifier)
+			return err
+		})
+		return lines, err
+	case c.gzipOverview:
+		return c.gzipOver(ctx, specifier)
+	case c.GetCapability("XFEATURE-COMPRESS") != "":
+		gzip, _ := c.HasCapabilityArgument("XFEATURE-COMPRESS", "GZIP")
+		if !gzip {
+			break
+		}
+		_, _, err = c.CommandContext(ctx, "XFEATURE COMPRESS GZIP TERMINATOR", 290)
+		if err != nil {
+			return nil, err
+		}
+		c.gzipOverview = true
+		return c.gzipOver(ctx, specifier)
+	}
+	return c.asLinesContext(ctx, "OVER "+specifier, 224)
+}
+
+// xzver reads an XZVER response: yEnc-encoded, DEFLATE-compressed
+// overview lines.
+func (c *Client) xzver(specifier string) ([]string, error) {
+	_, _, err := c.command("XZVER "+specifier, 224)
 	if err != nil {
 		return nil, err
 	}
-	return lines, nil
+	dr := c.conn.DotReader()
+	data, err := nntp.DecodeXZVer(dr)
+	if err != nil {
+		io.Copy(io.Discard, dr)
+		return nil, err
+	}
+	// Skip anything after the yEnc trailer.
+	if _, err := io.Copy(io.Discard, dr); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	rv := lines[:0]
+	for _, l := range lines {
+		l = strings.TrimSuffix(l, "\r")
+		if l == "" || l == "." {
+			continue
+		}
+		rv = append(rv, l)
+	}
+	return rv, nil
+}
+
+// gzipOver reads an overview response sent as a zlib stream of the
+// dot-terminated block, followed by a terminator line.
+func (c *Client) gzipOver(ctx context.Context, specifier string) (lines []string, err error) {
+	err = c.do(ctx, func() error {
+		_, _, err := c.command("XOVER "+specifier, 224)
+		if err != nil {
+			return err
+		}
+		zr, err := zlib.NewReader(c.conn.R)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		lines, err = textproto.NewReader(bufio.NewReader(zr)).ReadDotLines()
+		if err != nil {
+			return err
+		}
+		// Consume the checksum.
+		if _, err := io.Copy(io.Discard, zr); err != nil {
+			return err
+		}
+		term, err := c.conn.ReadLine()
+		if err != nil {
+			return err
+		}
+		if term != "." {
+			return textproto.ProtocolError("expected terminator, got " + strconv.Quote(term))
+		}
+		return nil
+	})
+	return
 }
 
 func (c *Client) HasTLS() bool {
@@ -344,19 +731,57 @@ func (c *Client) HasTLS() bool {
 // See https://datatracker.ietf.org/doc/html/rfc4642 and net/smtp.go, from
 // which this was adapted, and maybe NNTP.startls in Python's nntplib also.
 func (c *Client) StartTLS(config *tls.Config) error {
+	return c.StartTLSContext(context.Background(), config)
+}
+
+// StartTLSContext is StartTLS with a context.
+func (c *Client) StartTLSContext(ctx context.Context, config *tls.Config) error {
 	if c.tls {
-		return errors.New("TLS already active")
+		return ErrTLSActive
+	}
+	if c.compressed {
+		return ErrCompressionActive
 	}
-	_, _, err := c.Command("STARTTLS", 382)
+	_, _, err := c.CommandContext(ctx, "STARTTLS", 382)
 	if err != nil {
 		return err
 	}
 	c.netconn = tls.Client(c.netconn, config)
 	c.conn = textproto.NewConn(c.netconn)
 	c.tls = true
-	_, err = c.Capabilities()
+	_, err = c.CapabilitiesContext(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Compress turns on COMPRESS DEFLATE for the rest of the session.
+//
+// Use StartTLS first if needed; TLS can't be started once compression
+// is active.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8054
+func (c *Client) Compress() error {
+	return c.CompressContext(context.Background())
+}
+
+// CompressContext is Compress with a context.
+func (c *Client) CompressContext(ctx context.Context) error {
+	if c.compressed {
+		return ErrCompressionActive
+	}
+	_, _, err := c.CommandContext(ctx, "COMPRESS DEFLATE", 206)
 	if err != nil {
 		return err
 	}
+	c.netconn = nntp.NewDeflateConn(c.netconn)
+	c.conn = textproto.NewConn(c.netconn)
+	c.compressed = true
 	return nil
 }
+
+// IsCompressed reports whether COMPRESS DEFLATE is active.
+func (c *Client) IsCompressed() bool {
+	return c.compressed
+}
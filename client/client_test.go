@@ -0,0 +1,126 @@
+package nntpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer greets a client over a pipe, then answers each command
+// it expects with the given response lines.
+func fakeServer(t *testing.T, script map[string][]string) *Client {
+	a, b := net.Pipe()
+	go func() {
+		defer b.Close()
+		c := textproto.NewConn(b)
+		c.PrintfLine("200 hello")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			resp, ok := script[line]
+			if !ok {
+				resp = []string{"500 unexpected " + line}
+			}
+			// Write the response at once, as the pipe has no
+			// buffering and the client may not read it all.
+			c.W.WriteString(strings.Join(resp, "\r\n") + "\r\n")
+			c.W.Flush()
+		}
+	}()
+	c, err := NewConn(a)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	return c
+}
+
+func TestList(t *testing.T) {
+	c := fakeServer(t, map[string][]string{
+		"LIST":        {"215 active", "misc.test 3 1 y", "."},
+		"LIST ACTIVE": {"215 active", "alt.test 0 1 n", "."},
+	})
+	defer c.Close()
+
+	for sub, exp := range map[string]string{"": "misc.test", "ACTIVE": "alt.test"} {
+		groups, err := c.List(sub)
+		if err != nil {
+			t.Fatalf("List(%q): %v", sub, err)
+		}
+		if len(groups) != 1 || groups[0].Name != exp {
+			t.Errorf("List(%q): expected %v, got %v", sub, exp, groups)
+		}
+	}
+}
+
+func TestBodyStreams(t *testing.T) {
+	c := fakeServer(t, map[string][]string{
+		"BODY 1": {"222 1 <1@example.com> body", "one", "two", "."},
+		"BODY 2": {"222 2 <2@example.com> body", "partial"},
+		"STAT 1": {"223 1 <1@example.com> status"},
+	})
+	defer c.Close()
+
+	_, _, r, err := c.Body("1")
+	if err != nil {
+		t.Fatalf("Error getting body: %v", err)
+	}
+	if b, err := io.ReadAll(r); err != nil || string(b) != "one\ntwo\n" {
+		t.Fatalf("Expected the body, got %q (%v)", b, err)
+	}
+
+	// An unread body is skipped by the next command.
+	if _, _, _, err := c.Body("1"); err != nil {
+		t.Fatalf("Error getting body: %v", err)
+	}
+	if _, _, err := c.Stat("1"); err != nil {
+		t.Fatalf("Error after an unread body: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, r, err = c.BodyContext(ctx, "2")
+	if err != nil {
+		t.Fatalf("Error getting body: %v", err)
+	}
+	if b, err := io.ReadAll(r); err != context.DeadlineExceeded || string(b) != "partial\n" {
+		t.Fatalf("Expected the deadline to cut the body short, got %q (%v)", b, err)
+	}
+	if _, _, err := c.Stat("1"); err != ErrConnBroken {
+		t.Errorf("Expected a partial body to break the client, got %v", err)
+	}
+}
+
+func TestPipelineMalformedArticle(t *testing.T) {
+	c := fakeServer(t, map[string][]string{
+		"ARTICLE <1@example.com>": {"220 1 <1@example.com>",
+			"Message-Id: <1@example.com>", "", "one", "."},
+		"ARTICLE <2@example.com>": {"220 2 <2@example.com>",
+			" continuation without a header", "", "two", "."},
+		"ARTICLE <3@example.com>": {"220 3 <3@example.com>",
+			"Message-Id: <3@example.com>", "", "three", "."},
+		"STAT <3@example.com>": {"223 3 <3@example.com>"},
+	})
+	defer c.Close()
+
+	rv, err := c.ArticlesByID("<1@example.com>", "<2@example.com>", "<3@example.com>")
+	if err != nil {
+		t.Fatalf("Expected the batch to complete, got %v", err)
+	}
+	if rv[1].Err == nil || rv[1].Article != nil {
+		t.Errorf("Expected a parse error for the malformed article, got %+v", rv[1])
+	}
+	for _, i := range []int{0, 2} {
+		if rv[i].Err != nil || rv[i].Article.MessageID() != rv[i].Specifier {
+			t.Errorf("Expected article %v, got %+v", rv[i].Specifier, rv[i])
+		}
+	}
+	if _, _, err := c.Stat("<3@example.com>"); err != nil {
+		t.Errorf("Expected the client to remain usable, got %v", err)
+	}
+}
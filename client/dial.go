@@ -0,0 +1,106 @@
+package nntpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"github.com/dustin/go-nntp"
+)
+
+// A Dialer holds what's needed to open and log in to new connections.
+type Dialer struct {
+	// Network and address to connect to, as for net.Dial.  Network
+	// defaults to "tcp".
+	Network string
+	Addr    string
+	// TLSConfig, if set, connects with TLS from the start, as on
+	// port 563.
+	TLSConfig *tls.Config
+	// StartTLS, if set, upgrades new connections with STARTTLS.
+	StartTLS *tls.Config
+	// ModeReader issues MODE READER on new connections.
+	ModeReader bool
+	// User and Pass, if User is set, authenticate new connections
+	// with AUTHINFO USER/PASS.
+	User string
+	Pass string
+	// SASL, if set, authenticates new connections with AUTHINFO
+	// SASL instead of User and Pass.
+	SASL SASLClient
+	// Compress turns on COMPRESS DEFLATE once the rest of the setup
+	// is done.
+	Compress bool
+}
+
+// Dial opens and prepares a new client: it's upgraded with STARTTLS,
+// switched to reader mode, authenticated and compressed, as
+// configured.
+func (d *Dialer) Dial() (*Client, error) {
+	return d.DialContext(context.Background())
+}
+
+// DialContext is Dial with a context covering the whole setup.
+func (d *Dialer) DialContext(ctx context.Context) (*Client, error) {
+	network := d.Network
+	if network == "" {
+		network = "tcp"
+	}
+	var c *Client
+	var err error
+	if d.TLSConfig != nil {
+		c, err = DialTLSContext(ctx, network, d.Addr, d.TLSConfig)
+	} else {
+		c, err = DialContext(ctx, network, d.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = d.setup(ctx, c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (d *Dialer) setup(ctx context.Context, c *Client) error {
+	if d.StartTLS != nil {
+		if err := c.StartTLSContext(ctx, d.StartTLS); err != nil {
+			return err
+		}
+	}
+	if d.ModeReader {
+		if _, err := c.ModeReaderContext(ctx); err != nil {
+			return err
+		}
+	}
+	switch {
+	case d.SASL != nil:
+		if _, err := c.AuthenticateSASLContext(ctx, d.SASL); err != nil {
+			return err
+		}
+	case d.User != "":
+		if _, err := c.AuthenticateContext(ctx, d.User, d.Pass); err != nil {
+			return err
+		}
+	}
+	if d.Compress {
+		if err := c.CompressContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reusable reports whether a client may be used again after returning
+// err.  NNTP error responses leave the connection usable, except for
+// 400, which means the server is closing it.  Any other error, such
+// as a network failure or a malformed response, leaves the protocol
+// state unknown.
+func Reusable(err error) bool {
+	if err == nil {
+		return true
+	}
+	var ne *nntp.Error
+	return errors.As(err, &ne) && ne.Code != 400
+}
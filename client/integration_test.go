@@ -0,0 +1,241 @@
+package nntpclient_test
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/client"
+	"github.com/dustin/go-nntp/nntptest"
+	"github.com/dustin/go-nntp/server"
+)
+
+const article = "Message-Id: <1@example.com>\r\n" +
+	"From: test@example.com\r\n" +
+	"Newsgroups: misc.test\r\n" +
+	"Subject: hello\r\n" +
+	"\r\n" +
+	"Hello, world.\r\n"
+
+func newServer(t *testing.T) *nntptest.Server {
+	s := nntptest.NewServer(t, nil)
+	s.Backend.AddGroup("misc.test", "Testing", nntp.PostingPermitted)
+	s.Backend.AddGroup("alt.test", "More testing", nntp.PostingNotPermitted)
+	return s
+}
+
+func TestReading(t *testing.T) {
+	s := newServer(t)
+	if _, err := s.Backend.AddArticle(article); err != nil {
+		t.Fatalf("Error adding article: %v", err)
+	}
+	c := s.Client(t)
+
+	groups, err := c.List("")
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("Expected two groups, got %v (%v)", groups, err)
+	}
+	if _, err := c.Group("no.such.group"); !errors.Is(err, nntp.ErrNoSuchGroup) {
+		t.Errorf("Expected ErrNoSuchGroup, got %v", err)
+	}
+	g, err := c.Group("misc.test")
+	if err != nil || g.Count != 1 || g.Low != 1 || g.High != 1 {
+		t.Fatalf("Unexpected group %+v (%v)", g, err)
+	}
+
+	num, id, a, err := c.Article("1")
+	if err != nil || num != 1 || id != "<1@example.com>" {
+		t.Fatalf("Unexpected article %d %s (%v)", num, id, err)
+	}
+	body, _ := io.ReadAll(a.Body)
+	if a.Header.Get("Subject") != "hello" || string(body) != "Hello, world.\n" {
+		t.Errorf("Unexpected article contents %v %q", a.Header, body)
+	}
+	if _, _, _, err := c.Head("<2@example.com>"); !errors.Is(err, nntp.ErrNoSuchArticle) {
+		t.Errorf("Expected ErrNoSuchArticle, got %v", err)
+	}
+
+	lines, err := c.Over("1-")
+	if err != nil || len(lines) != 1 || !strings.HasPrefix(lines[0], "1\thello\t") {
+		t.Errorf("Unexpected overview %q (%v)", lines, err)
+	}
+}
+
+func TestPosting(t *testing.T) {
+	s := newServer(t)
+	c := s.Client(t)
+	if err := c.Post(strings.NewReader(article)); err != nil {
+		t.Fatalf("Error posting: %v", err)
+	}
+	if err := c.Post(strings.NewReader(article)); !errors.Is(err, nntp.ErrPostingFailed) {
+		t.Errorf("Expected duplicate post to fail, got %v", err)
+	}
+	results, err := c.Pipeline().Head("<1@example.com>", "<2@example.com>")
+	if err != nil {
+		t.Fatalf("Error pipelining: %v", err)
+	}
+	if results[0].Err != nil || results[0].Article.Header.Get("From") != "test@example.com" {
+		t.Errorf("Unexpected first result %+v", results[0])
+	}
+	if !errors.Is(results[1].Err, nntp.ErrNoSuchArticle) {
+		t.Errorf("Expected ErrNoSuchArticle, got %v", results[1].Err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newServer(t)
+	s.Authenticator = nntpserver.StaticAuthenticator{"user": "secret"}
+
+	c := s.Client(t)
+	if _, err := c.Group("misc.test"); !errors.Is(err, nntp.ErrNotAuthenticated) {
+		t.Errorf("Expected ErrNotAuthenticated, got %v", err)
+	}
+	if _, err := c.AuthenticateSASL(nntpclient.PlainAuth("", "user", "wrong")); !errors.Is(err, nntp.ErrAuthRejected) {
+		t.Errorf("Expected ErrAuthRejected, got %v", err)
+	}
+	if _, err := c.AuthenticateSASL(nntpclient.PlainAuth("", "user", "secret")); err != nil {
+		t.Fatalf("Error authenticating: %v", err)
+	}
+	if _, err := c.Group("misc.test"); err != nil {
+		t.Errorf("Error selecting group after authenticating: %v", err)
+	}
+
+	c = s.PipeClient(t)
+	if _, err := c.Authenticate("user", "secret"); err != nil {
+		t.Fatalf("Error authenticating with USER/PASS: %v", err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	s := newServer(t)
+	s.Backend.AddArticle(article)
+	c := s.Client(t)
+	if err := c.Compress(); err != nil {
+		t.Fatalf("Error compressing: %v", err)
+	}
+	if _, err := c.Group("misc.test"); err != nil {
+		t.Fatalf("Error selecting group: %v", err)
+	}
+	if lines, err := c.Over("1"); err != nil || len(lines) != 1 {
+		t.Errorf("Unexpected overview %q (%v)", lines, err)
+	}
+}
+
+// overviewServer serves two articles, logging the commands it handles.
+func overviewServer(t *testing.T, compress bool) (*nntptest.Server, *commandLog) {
+	s := newServer(t)
+	s.CompressOverview = compress
+	for _, id := range []string{"<1@", "<2@"} {
+		if _, err := s.Backend.AddArticle(strings.Replace(article, "<1@", id, 1)); err != nil {
+			t.Fatalf("Error adding article: %v", err)
+		}
+	}
+	log := &commandLog{}
+	s.Use(log.middleware)
+	return s, log
+}
+
+// checkOverview reads the overview of both articles twice, and checks
+// the connection is still in step afterwards.
+func checkOverview(t *testing.T, c *nntpclient.Client) {
+	t.Helper()
+	if _, err := c.Capabilities(); err != nil {
+		t.Fatalf("Error getting capabilities: %v", err)
+	}
+	if _, err := c.Group("misc.test"); err != nil {
+		t.Fatalf("Error selecting group: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		lines, err := c.Over("1-2")
+		if err != nil || len(lines) != 2 ||
+			!strings.HasPrefix(lines[0], "1\thello\t") ||
+			!strings.HasPrefix(lines[1], "2\thello\t") {
+			t.Fatalf("Unexpected overview %q (%v)", lines, err)
+		}
+	}
+	if _, err := c.Group("alt.test"); err != nil {
+		t.Errorf("Error selecting group after overview: %v", err)
+	}
+}
+
+func TestOverview(t *testing.T) {
+	s, log := overviewServer(t, false)
+	c := s.Client(t)
+	checkOverview(t, c)
+	if c.GetCapability("XZVER") != "" || c.GetCapability("XFEATURE-COMPRESS") != "" {
+		t.Errorf("Compressed overview advertised without CompressOverview")
+	}
+	if n := log.count("over"); n != 2 {
+		t.Errorf("Expected plain OVER without compression, got %v", log.cmds)
+	}
+}
+
+func TestOverviewXZVer(t *testing.T) {
+	s, log := overviewServer(t, true)
+	c := s.Client(t)
+	checkOverview(t, c)
+	if n := log.count("xzver"); n != 2 {
+		t.Errorf("Expected XZVER to be preferred, got %v", log.cmds)
+	}
+}
+
+func TestOverviewGzip(t *testing.T) {
+	s, log := overviewServer(t, true)
+	// Only offer XFEATURE COMPRESS GZIP.
+	delete(s.Handlers, "xzver")
+	c := s.Client(t)
+	checkOverview(t, c)
+	if c.GetCapability("XZVER") != "" {
+		t.Errorf("XZVER advertised without a handler")
+	}
+	if log.count("xfeature") != 1 || log.count("xover") != 2 {
+		t.Errorf("Expected XFEATURE once, then compressed XOVER, got %v", log.cmds)
+	}
+}
+
+func TestOverviewGzipTerminator(t *testing.T) {
+	s, _ := overviewServer(t, true)
+	// Reading the next line after the compressed block shows whether
+	// a terminator was sent.
+	for feature, exp := range map[string]string{
+		"XFEATURE COMPRESS GZIP TERMINATOR": ".",
+		"XFEATURE COMPRESS GZIP":            "205 bye",
+	} {
+		nc, err := net.Dial("tcp", s.Addr)
+		if err != nil {
+			t.Fatalf("Error connecting: %v", err)
+		}
+		defer nc.Close()
+		nc.SetDeadline(time.Now().Add(nntptest.Timeout))
+		br := bufio.NewReader(nc)
+		fmt.Fprintf(nc, "GROUP misc.test\r\n%s\r\nXOVER 1-2\r\n", feature)
+		for _, code := range []string{"200", "211", "290", "224"} {
+			if line, err := br.ReadString('\n'); !strings.HasPrefix(line, code) {
+				t.Fatalf("%v: expected %v, got %q (%v)", feature, code, line, err)
+			}
+		}
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			t.Fatalf("%v: error reading compressed overview: %v", feature, err)
+		}
+		// Read to the end of the stream, including its checksum.
+		block, err := io.ReadAll(zr)
+		if err != nil || strings.Count(string(block), "\r\n") != 3 ||
+			!strings.HasSuffix(string(block), "\r\n.\r\n") {
+			t.Fatalf("%v: unexpected overview %q (%v)", feature, block, err)
+		}
+		fmt.Fprintf(nc, "QUIT\r\n")
+		if line, err := textproto.NewReader(br).ReadLine(); line != exp {
+			t.Errorf("%v: expected %q after the compressed block, got %q (%v)",
+				feature, exp, line, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package nntpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dustin/go-nntp"
+)
+
+// A Pipeline sends a batch of commands without waiting for each
+// response before sending the next, as allowed by RFC 3977 section
+// 3.5, saving a round trip per command.
+//
+// Results are returned in the order requested.  An error response to
+// one command, such as 430 for a missing article, or an article that
+// can't be parsed, is reported in that command's result and doesn't
+// affect the others.
+type Pipeline struct {
+	// Window is the maximum number of commands in flight.  Zero
+	// means 100.
+	Window int
+
+	c *Client
+}
+
+// A StatResult is the outcome of one pipelined STAT.
+type StatResult struct {
+	// The message-id or number requested.
+	Specifier string
+	Num       int64
+	MessageID string
+	Err       error
+}
+
+// An ArticleResult is the outcome of one pipelined ARTICLE or HEAD.
+type ArticleResult struct {
+	// The message-id or number requested.
+	Specifier string
+	Num       int64
+	MessageID string
+	Article   *nntp.Article
+	Err       error
+}
+
+// Pipeline returns a Pipeline issuing commands on c.
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// ArticlesByID fetches many articles in a single pipeline.
+func (c *Client) ArticlesByID(ids ...string) ([]ArticleResult, error) {
+	return c.Pipeline().Article(ids...)
+}
+
+// Stat checks whether each of the given articles exists.
+func (p *Pipeline) Stat(specifiers ...string) ([]StatResult, error) {
+	return p.StatContext(context.Background(), specifiers...)
+}
+
+// StatContext is Stat with a context.
+func (p *Pipeline) StatContext(ctx context.Context,
+	specifiers ...string) ([]StatResult, error) {
+
+	rv := make([]StatResult, len(specifiers))
+	err := p.run(ctx, "STAT", 223, specifiers, func(i int, msg string) error {
+		rv[i].Num, rv[i].MessageID, rv[i].Err = parseArticleResponse(msg)
+		return nil
+	}, func(i int, err error) {
+		rv[i].Err = err
+	})
+	for i := range rv {
+		rv[i].Specifier = specifiers[i]
+	}
+	return rv, err
+}
+
+// Article fetches each of the given articles.
+func (p *Pipeline) Article(specifiers ...string) ([]ArticleResult, error) {
+	return p.ArticleContext(context.Background(), specifiers...)
+}
+
+// ArticleContext is Article with a context.
+func (p *Pipeline) ArticleContext(ctx context.Context,
+	specifiers ...string) ([]ArticleResult, error) {
+
+	return p.articles(ctx, "ARTICLE", 220, specifiers)
+}
+
+// Head fetches the headers of each of the given articles.
+func (p *Pipeline) Head(specifiers ...string) ([]ArticleResult, error) {
+	return p.HeadContext(context.Background(), specifiers...)
+}
+
+// HeadContext is Head with a context.
+func (p *Pipeline) HeadContext(ctx context.Context,
+	specifiers ...string) ([]ArticleResult, error) {
+
+	return p.articles(ctx, "HEAD", 221, specifiers)
+}
+
+func (p *Pipeline) articles(ctx context.Context, cmd string, expected int,
+	specifiers []string) ([]ArticleResult, error) {
+
+	rv := make([]ArticleResult, len(specifiers))
+	err := p.run(ctx, cmd, expected, specifiers, func(i int, msg string) error {
+		// Read the whole data block first, so a response that fails
+		// to parse doesn't leave the rest of it on the wire.
+		raw, err := p.c.conn.ReadDotBytes()
+		if err != nil {
+			return err
+		}
+		rv[i].Num, rv[i].MessageID, rv[i].Err = parseArticleResponse(msg)
+		if rv[i].Err == nil {
+			rv[i].Article, rv[i].Err = nntp.ReadArticle(bytes.NewReader(raw))
+		}
+		return nil
+	}, func(i int, err error) {
+		rv[i].Err = err
+	})
+	for i := range rv {
+		rv[i].Specifier = specifiers[i]
+	}
+	return rv, err
+}
+
+// run sends "cmd specifier" for each specifier while reading the
+// responses.  ok is called to read each successful response, and
+// failed with each error response.  Any other error, including one
+// returned by ok, means the responses can no longer be matched to the
+// commands, so it aborts the pipeline and leaves the client unusable.
+func (p *Pipeline) run(ctx context.Context, cmd string, expected int,
+	specifiers []string, ok func(int, string) error, failed func(int, error)) error {
+
+	c := p.c
+	window := p.Window
+	if window <= 0 {
+		window = 100
+	}
+
+	return c.do(ctx, func() error {
+		ids := make([]uint, len(specifiers))
+		for i := range ids {
+			ids[i] = c.conn.Next()
+		}
+
+		inflight := make(chan struct{}, window)
+		abort := make(chan struct{})
+		werr := make(chan error, 1)
+		go func() {
+			for i, spec := range specifiers {
+				select {
+				case inflight <- struct{}{}:
+				case <-abort:
+					werr <- nil
+					return
+				}
+				c.conn.StartRequest(ids[i])
+				err := c.conn.PrintfLine("%s %s", cmd, spec)
+				c.conn.EndRequest(ids[i])
+				if err != nil {
+					werr <- err
+					return
+				}
+			}
+			werr <- nil
+		}()
+
+		var err error
+		for i := range specifiers {
+			c.conn.StartResponse(ids[i])
+			var msg string
+			_, msg, err = c.readCodeLine(expected)
+			var ne *nntp.Error
+			switch {
+			case err == nil:
+				err = ok(i, msg)
+			case errors.As(err, &ne) && ne.Code/100 != 2:
+				failed(i, err)
+				err = nil
+			}
+			c.conn.EndResponse(ids[i])
+			if err != nil {
+				break
+			}
+			<-inflight
+		}
+
+		if err != nil {
+			c.broken = true
+			close(abort)
+			// Unblock the writer if it's stuck.
+			c.netconn.SetDeadline(time.Unix(1, 0))
+			<-werr
+			return err
+		}
+		return <-werr
+	})
+}
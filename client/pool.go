@@ -0,0 +1,166 @@
+package nntpclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned when getting a client from a closed Pool.
+var ErrPoolClosed = errors.New("pool closed")
+
+type pooledClient struct {
+	client   *Client
+	lastUsed time.Time
+}
+
+// A Pool maintains up to a fixed number of connections to a server,
+// handing each out to one user at a time.
+//
+// Clients are not safe for concurrent use, so share a Pool between
+// goroutines rather than a Client.
+type Pool struct {
+	// IdleCheck is how long a connection may sit idle before it's
+	// checked with DATE when next handed out.  Zero means one
+	// minute.
+	IdleCheck time.Duration
+
+	dialer *Dialer
+	slots  chan struct{}
+
+	mu     sync.Mutex
+	idle   []*pooledClient
+	closed bool
+}
+
+// NewPool creates a pool of at most max connections made by d.  It
+// panics if max isn't positive.
+func NewPool(d *Dialer, max int) *Pool {
+	if max <= 0 {
+		panic("nntpclient: NewPool needs a positive max")
+	}
+	return &Pool{
+		dialer: d,
+		slots:  make(chan struct{}, max),
+	}
+}
+
+// A Lease is exclusive use of one of a Pool's clients.
+type Lease struct {
+	pool *Pool
+	pc   *pooledClient
+}
+
+// Get leases a client, waiting for one to become available if the
+// pool is at its limit.  If group is not empty, the client will have
+// it selected.
+//
+// Callers must Release the lease when done with it.
+func (p *Pool) Get(ctx context.Context, group string) (*Lease, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	pc, err := p.get(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	l := &Lease{pool: p, pc: pc}
+
+	if group != "" && pc.client.CurrentGroup() != group {
+		if _, err := pc.client.GroupContext(ctx, group); err != nil {
+			l.Release(err)
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// get finds a healthy idle client or dials a new one.
+func (p *Pool) get(ctx context.Context) (*pooledClient, error) {
+	check := p.IdleCheck
+	if check == 0 {
+		check = time.Minute
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		pc := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(pc.lastUsed) < check {
+			return pc, nil
+		}
+		// Any reply other than 400 means the connection is alive,
+		// even from a server without DATE.
+		if _, err := pc.client.DateContext(ctx); Reusable(err) {
+			return pc, nil
+		}
+		pc.client.Close()
+	}
+
+	c, err := p.dialer.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledClient{client: c}, nil
+}
+
+// Client returns the leased client.
+func (l *Lease) Client() *Client {
+	return l.pc.client
+}
+
+// Release returns the client to the pool.  err should be the last
+// error the client returned, if any; clients are discarded rather than
+// reused when Reusable(err) is false.
+func (l *Lease) Release(err error) {
+	if l.pc == nil {
+		return
+	}
+	pc, p := l.pc, l.pool
+	l.pc = nil
+
+	p.mu.Lock()
+	if Reusable(err) && !p.closed {
+		pc.lastUsed = time.Now()
+		p.idle = append(p.idle, pc)
+		pc = nil
+	}
+	p.mu.Unlock()
+
+	if pc != nil {
+		pc.client.Close()
+	}
+	<-p.slots
+}
+
+// Close closes all idle connections.  Leased connections are closed
+// as they're released.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var rv error
+	for _, pc := range idle {
+		if err := pc.client.Close(); err != nil && rv == nil {
+			rv = err
+		}
+	}
+	return rv
+}
@@ -0,0 +1,212 @@
+package nntpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/client"
+	"github.com/dustin/go-nntp/server"
+)
+
+// commandLog records the commands a server handles.
+type commandLog struct {
+	mu   sync.Mutex
+	cmds []string
+}
+
+func (l *commandLog) middleware(next nntpserver.Handler) nntpserver.Handler {
+	return func(args []string, s *nntpserver.Session, c *textproto.Conn) error {
+		l.mu.Lock()
+		l.cmds = append(l.cmds, s.Command())
+		l.mu.Unlock()
+		return next(args, s, c)
+	}
+}
+
+func (l *commandLog) count(cmd string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, c := range l.cmds {
+		if c == cmd {
+			n++
+		}
+	}
+	return n
+}
+
+// newPool returns a pool of max connections to a server that drops
+// the connection on DATE unless alive is set.
+func newPool(t *testing.T, max int) (*nntpclient.Pool, *commandLog, *atomic.Bool) {
+	s := newServer(t)
+	var alive atomic.Bool
+	alive.Store(true)
+	s.Use(func(next nntpserver.Handler) nntpserver.Handler {
+		return func(args []string, s *nntpserver.Session, c *textproto.Conn) error {
+			if s.Command() == "date" && !alive.Load() {
+				return errors.New("connection lost")
+			}
+			return next(args, s, c)
+		}
+	})
+	log := &commandLog{}
+	s.Use(log.middleware)
+	p := nntpclient.NewPool(&nntpclient.Dialer{Addr: s.Addr}, max)
+	t.Cleanup(func() { p.Close() })
+	return p, log, &alive
+}
+
+func get(t *testing.T, p *nntpclient.Pool, group string) *nntpclient.Lease {
+	t.Helper()
+	l, err := p.Get(context.Background(), group)
+	if err != nil {
+		t.Fatalf("Error getting a client for %q: %v", group, err)
+	}
+	return l
+}
+
+func TestPoolGroups(t *testing.T) {
+	p, log, _ := newPool(t, 2)
+
+	l := get(t, p, "misc.test")
+	c := l.Client()
+	if c.CurrentGroup() != "misc.test" {
+		t.Errorf("Expected misc.test selected, got %q", c.CurrentGroup())
+	}
+	l.Release(nil)
+
+	l = get(t, p, "misc.test")
+	if l.Client() != c {
+		t.Errorf("Expected the idle client to be reused")
+	}
+	l.Release(nil)
+	if n := log.count("group"); n != 1 {
+		t.Errorf("Expected the group to be selected once, got %d GROUPs", n)
+	}
+
+	l = get(t, p, "alt.test")
+	if l.Client() != c || c.CurrentGroup() != "alt.test" {
+		t.Errorf("Expected the idle client to switch to alt.test, got %q",
+			c.CurrentGroup())
+	}
+	l.Release(nil)
+
+	if _, err := p.Get(context.Background(), "no.such.group"); !errors.Is(err, nntp.ErrNoSuchGroup) {
+		t.Errorf("Expected ErrNoSuchGroup, got %v", err)
+	}
+	// The failed GROUP left the client usable, and its slot free.
+	l = get(t, p, "")
+	if l.Client() != c {
+		t.Errorf("Expected the client to be kept after a 411")
+	}
+	l.Release(nil)
+}
+
+func TestPoolLimit(t *testing.T) {
+	p, _, _ := newPool(t, 1)
+	l := get(t, p, "")
+	c := l.Client()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Get beyond the limit to wait for the context, got %v", err)
+	}
+
+	done := make(chan *nntpclient.Lease)
+	go func() {
+		l, _ := p.Get(context.Background(), "")
+		done <- l
+	}()
+	l.Release(nil)
+	select {
+	case l2 := <-done:
+		if l2 == nil || l2.Client() != c {
+			t.Errorf("Expected the released client to be handed to the waiter")
+		}
+		l2.Release(nil)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Waiting Get wasn't woken by Release")
+	}
+}
+
+func TestPoolIdleCheck(t *testing.T) {
+	p, log, alive := newPool(t, 1)
+	p.IdleCheck = time.Nanosecond
+
+	l := get(t, p, "")
+	c := l.Client()
+	l.Release(nil)
+	time.Sleep(time.Millisecond)
+
+	l = get(t, p, "")
+	if l.Client() != c || log.count("date") != 1 {
+		t.Errorf("Expected the idle client to be checked with DATE and reused")
+	}
+	l.Release(nil)
+	time.Sleep(time.Millisecond)
+
+	alive.Store(false)
+	l = get(t, p, "")
+	if l.Client() == c {
+		t.Errorf("Expected a client whose connection dropped to be replaced")
+	}
+	l.Release(nil)
+}
+
+func TestPoolIdleCheckWithoutDate(t *testing.T) {
+	s := newServer(t)
+	delete(s.Handlers, "date")
+	p := nntpclient.NewPool(&nntpclient.Dialer{Addr: s.Addr}, 1)
+	defer p.Close()
+	p.IdleCheck = time.Nanosecond
+
+	l := get(t, p, "")
+	c := l.Client()
+	l.Release(nil)
+	time.Sleep(time.Millisecond)
+
+	l = get(t, p, "")
+	if l.Client() != c {
+		t.Errorf("Expected a client answering DATE with 500 to be reused")
+	}
+	l.Release(nil)
+}
+
+func TestPoolDiscard(t *testing.T) {
+	p, _, _ := newPool(t, 1)
+
+	l := get(t, p, "")
+	c := l.Client()
+	l.Release(nntp.ErrNoSuchArticle)
+	l = get(t, p, "")
+	if l.Client() != c {
+		t.Errorf("Expected the client to be kept after an NNTP error")
+	}
+
+	l.Release(errors.New("broken pipe"))
+	l = get(t, p, "")
+	if l.Client() == c {
+		t.Errorf("Expected the client to be discarded after a non-NNTP error")
+	}
+	l.Release(nil)
+}
+
+func TestNewPoolMax(t *testing.T) {
+	for _, max := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected NewPool(d, %d) to panic", max)
+				}
+			}()
+			nntpclient.NewPool(&nntpclient.Dialer{}, max)
+		}()
+	}
+}
@@ -0,0 +1,250 @@
+package nntpclient
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/dustin/go-nntp"
+)
+
+// A ResilientClient reconnects when its connection is lost.
+//
+// Each new connection is set up by the Dialer (TLS, MODE READER,
+// authentication) and has the last selected group selected again.
+// Idempotent commands are retried with exponential backoff after
+// connection failures.  POST is never retried, since the server may
+// have accepted the article before the connection dropped.
+//
+// NNTP error responses such as 430 are returned as is, without
+// reconnecting or retrying.
+//
+// The context given to a ...Context method covers connecting, every
+// attempt and the waits between them.
+type ResilientClient struct {
+	// Retries is the number of times an idempotent command is retried.
+	Retries int
+	// Backoff is the delay before the first retry.  It doubles with
+	// each further retry.
+	Backoff time.Duration
+
+	dialer *Dialer
+	client *Client
+	group  string
+}
+
+// NewResilient creates a ResilientClient using d to make connections.
+// No connection is made until the first command.
+func NewResilient(d *Dialer) *ResilientClient {
+	return &ResilientClient{
+		Retries: 3,
+		Backoff: 250 * time.Millisecond,
+		dialer:  d,
+	}
+}
+
+// conn returns the current client, connecting if necessary.
+func (r *ResilientClient) conn(ctx context.Context) (*Client, error) {
+	if r.client != nil {
+		return r.client, nil
+	}
+	c, err := r.dialer.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.group != "" {
+		if _, err := c.GroupContext(ctx, r.group); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	r.client = c
+	return c, nil
+}
+
+// drop closes the current connection after a failure.
+func (r *ResilientClient) drop() {
+	if r.client != nil {
+		r.client.Close()
+		r.client = nil
+	}
+}
+
+// do runs f against a connected client, retrying failures that leave
+// the connection unusable if idempotent is set.
+func (r *ResilientClient) do(ctx context.Context, idempotent bool,
+	f func(c *Client) error) error {
+
+	backoff := r.Backoff
+	for attempt := 0; ; attempt++ {
+		c, err := r.conn(ctx)
+		if err == nil {
+			err = f(c)
+			if Reusable(err) {
+				return err
+			}
+			r.drop()
+		}
+		if !idempotent || attempt >= r.Retries || ctx.Err() != nil {
+			return err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Client returns the current underlying client, connecting if
+// necessary.  Commands issued directly on it are not retried.
+func (r *ResilientClient) Client() (*Client, error) {
+	return r.ClientContext(context.Background())
+}
+
+// ClientContext is Client with a context.
+func (r *ResilientClient) ClientContext(ctx context.Context) (*Client, error) {
+	return r.conn(ctx)
+}
+
+// Close the current connection, if any.
+func (r *ResilientClient) Close() error {
+	if r.client == nil {
+		return nil
+	}
+	err := r.client.Close()
+	r.client = nil
+	return err
+}
+
+// Group selects a group, which is selected again after reconnecting.
+func (r *ResilientClient) Group(name string) (nntp.Group, error) {
+	return r.GroupContext(context.Background(), name)
+}
+
+// GroupContext is Group with a context.
+func (r *ResilientClient) GroupContext(ctx context.Context,
+	name string) (rv nntp.Group, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		rv, err = c.GroupContext(ctx, name)
+		return err
+	})
+	if err == nil {
+		r.group = name
+	}
+	return
+}
+
+// Article grabs an article.
+func (r *ResilientClient) Article(specifier string) (int64, string, *nntp.Article, error) {
+	return r.ArticleContext(context.Background(), specifier)
+}
+
+// ArticleContext is Article with a context.
+func (r *ResilientClient) ArticleContext(ctx context.Context,
+	specifier string) (n int64, id string, a *nntp.Article, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		n, id, a, err = c.ArticleContext(ctx, specifier)
+		return err
+	})
+	return
+}
+
+// Head gets the headers for an article.
+func (r *ResilientClient) Head(specifier string) (int64, string, *nntp.Article, error) {
+	return r.HeadContext(context.Background(), specifier)
+}
+
+// HeadContext is Head with a context.
+func (r *ResilientClient) HeadContext(ctx context.Context,
+	specifier string) (n int64, id string, a *nntp.Article, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		n, id, a, err = c.HeadContext(ctx, specifier)
+		return err
+	})
+	return
+}
+
+// Body gets the body of an article.  Only the command is retried: the
+// body is streamed, so a failure while reading it is returned as is.
+func (r *ResilientClient) Body(specifier string) (int64, string, io.Reader, error) {
+	return r.BodyContext(context.Background(), specifier)
+}
+
+// BodyContext is Body with a context, which also covers reading the
+// body.
+func (r *ResilientClient) BodyContext(ctx context.Context,
+	specifier string) (n int64, id string, body io.Reader, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		n, id, body, err = c.BodyContext(ctx, specifier)
+		return err
+	})
+	return
+}
+
+// Stat checks whether an article exists.
+func (r *ResilientClient) Stat(specifier string) (int64, string, error) {
+	return r.StatContext(context.Background(), specifier)
+}
+
+// StatContext is Stat with a context.
+func (r *ResilientClient) StatContext(ctx context.Context,
+	specifier string) (n int64, id string, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		n, id, err = c.StatContext(ctx, specifier)
+		return err
+	})
+	return
+}
+
+// Over returns a list of raw overview lines with tab-separated fields.
+func (r *ResilientClient) Over(specifier string) ([]string, error) {
+	return r.OverContext(context.Background(), specifier)
+}
+
+// OverContext is Over with a context.
+func (r *ResilientClient) OverContext(ctx context.Context,
+	specifier string) (rv []string, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		rv, err = c.OverContext(ctx, specifier)
+		return err
+	})
+	return
+}
+
+// List groups.
+func (r *ResilientClient) List(sub string) ([]nntp.Group, error) {
+	return r.ListContext(context.Background(), sub)
+}
+
+// ListContext is List with a context.
+func (r *ResilientClient) ListContext(ctx context.Context,
+	sub string) (rv []nntp.Group, err error) {
+
+	err = r.do(ctx, true, func(c *Client) error {
+		rv, err = c.ListContext(ctx, sub)
+		return err
+	})
+	return
+}
+
+// Post a new article.  It is never retried.
+func (r *ResilientClient) Post(article io.Reader) error {
+	return r.PostContext(context.Background(), article)
+}
+
+// PostContext is Post with a context.
+func (r *ResilientClient) PostContext(ctx context.Context, article io.Reader) error {
+	return r.do(ctx, false, func(c *Client) error {
+		return c.PostContext(ctx, article)
+	})
+}
@@ -0,0 +1,218 @@
+package nntpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/client"
+	"github.com/dustin/go-nntp/server"
+)
+
+var errDropped = errors.New("dropped by test")
+
+// flakyServer records each session's commands, and can drop
+// connections on demand.
+type flakyServer struct {
+	mu       sync.Mutex
+	sessions []*nntpserver.Session
+	cmds     map[*nntpserver.Session][]string
+	conns    []*textproto.Conn
+	// Commands to drop the connection on, and how many more times.
+	drops map[string]int
+}
+
+func newFlakyServer(t *testing.T) (*flakyServer, *nntpclient.Dialer) {
+	s := newServer(t)
+	s.Authenticator = nntpserver.StaticAuthenticator{"user": "secret"}
+	if _, err := s.Backend.AddArticle(article); err != nil {
+		t.Fatalf("Error adding article: %v", err)
+	}
+	f := &flakyServer{
+		cmds:  map[*nntpserver.Session][]string{},
+		drops: map[string]int{},
+	}
+	s.Use(f.middleware)
+	return f, &nntpclient.Dialer{
+		Addr:       s.Addr,
+		ModeReader: true,
+		User:       "user",
+		Pass:       "secret",
+	}
+}
+
+func (f *flakyServer) middleware(next nntpserver.Handler) nntpserver.Handler {
+	return func(args []string, s *nntpserver.Session, c *textproto.Conn) error {
+		f.mu.Lock()
+		if _, ok := f.cmds[s]; !ok {
+			f.sessions = append(f.sessions, s)
+			f.conns = append(f.conns, c)
+		}
+		f.cmds[s] = append(f.cmds[s], s.Command())
+		drop := f.drops[s.Command()] > 0
+		if drop {
+			f.drops[s.Command()]--
+		}
+		f.mu.Unlock()
+		if drop {
+			return errDropped
+		}
+		return next(args, s, c)
+	}
+}
+
+// dropAll closes every connection, as an idle timeout would.
+func (f *flakyServer) dropAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+}
+
+func (f *flakyServer) dropNext(cmd string, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drops[cmd] = n
+}
+
+// session returns the commands sent in the i'th session.
+func (f *flakyServer) session(i int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i >= len(f.sessions) {
+		return ""
+	}
+	return strings.Join(f.cmds[f.sessions[i]], " ")
+}
+
+func (f *flakyServer) count(cmd string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, cmds := range f.cmds {
+		for _, c := range cmds {
+			if c == cmd {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestResilientRedial(t *testing.T) {
+	f, d := newFlakyServer(t)
+	r := nntpclient.NewResilient(d)
+	r.Backoff = time.Millisecond
+	defer r.Close()
+
+	if _, err := r.Group("misc.test"); err != nil {
+		t.Fatalf("Error selecting group: %v", err)
+	}
+	f.dropAll()
+
+	n, id, _, err := r.Article("1")
+	if err != nil || n != 1 || id != "<1@example.com>" {
+		t.Fatalf("Expected article 1 after reconnecting, got %d %s (%v)", n, id, err)
+	}
+	exp := "mode authinfo authinfo group article"
+	if got := f.session(1); got != exp {
+		t.Errorf("Expected the new session to be set up with %q, got %q", exp, got)
+	}
+}
+
+func TestResilientRetries(t *testing.T) {
+	f, d := newFlakyServer(t)
+	r := nntpclient.NewResilient(d)
+	r.Retries = 3
+	r.Backoff = 10 * time.Millisecond
+	defer r.Close()
+	if _, err := r.Group("misc.test"); err != nil {
+		t.Fatalf("Error selecting group: %v", err)
+	}
+
+	f.dropNext("article", 2)
+	start := time.Now()
+	if _, _, _, err := r.Article("1"); err != nil {
+		t.Fatalf("Expected ARTICLE to succeed on the third attempt, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected backoffs of 10ms and 20ms, retried within %v", elapsed)
+	}
+	if n := f.count("article"); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+
+	f.dropNext("article", 10)
+	if _, _, _, err := r.Article("1"); err == nil || nntpclient.Reusable(err) {
+		t.Errorf("Expected a connection error after running out of retries, got %v", err)
+	}
+	if n := f.count("article"); n != 3+4 {
+		t.Errorf("Expected 1 attempt and 3 retries, got %d", n-3)
+	}
+
+	f.dropNext("article", 0)
+	if _, _, _, err := r.Article("<2@example.com>"); !errors.Is(err, nntp.ErrNoSuchArticle) {
+		t.Errorf("Expected a 430 to be returned without retrying, got %v", err)
+	}
+	if n := f.count("article"); n != 3+4+1 {
+		t.Errorf("Expected no retry of a 430, got %d attempts", n-3-4)
+	}
+}
+
+func TestResilientPostNotRetried(t *testing.T) {
+	f, d := newFlakyServer(t)
+	r := nntpclient.NewResilient(d)
+	r.Backoff = time.Millisecond
+	defer r.Close()
+
+	f.dropNext("post", 1)
+	post := strings.Replace(article, "<1@", "<2@", 1)
+	if err := r.Post(strings.NewReader(post)); err == nil {
+		t.Fatalf("Expected POST to fail when the connection drops")
+	}
+	if n := f.count("post"); n != 1 {
+		t.Errorf("Expected POST to be sent once, got %d", n)
+	}
+
+	if err := r.Post(strings.NewReader(post)); err != nil {
+		t.Errorf("Expected a new POST to reconnect and succeed, got %v", err)
+	}
+	if n := f.count("post"); n != 2 {
+		t.Errorf("Expected 2 POSTs in all, got %d", n)
+	}
+}
+
+func TestResilientContext(t *testing.T) {
+	f, d := newFlakyServer(t)
+	r := nntpclient.NewResilient(d)
+	r.Backoff = time.Hour
+	defer r.Close()
+	if _, err := r.GroupContext(context.Background(), "misc.test"); err != nil {
+		t.Fatalf("Error selecting group: %v", err)
+	}
+
+	f.dropNext("article", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, _, err := r.ArticleContext(ctx, "1"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the context to end the backoff, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Backoff wasn't interrupted, took %v", elapsed)
+	}
+	if n := f.count("article"); n != 1 {
+		t.Errorf("Expected no retry after the context ended, got %d attempts", n)
+	}
+
+	// The next command reconnects.
+	if _, _, _, err := r.ArticleContext(context.Background(), "1"); err != nil {
+		t.Errorf("Error after reconnecting: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package nntpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/dustin/go-nntp"
+)
+
+// A SASLClient is the client side of a SASL mechanism, used with
+// AuthenticateSASL.
+type SASLClient interface {
+	// Start returns the mechanism name and the initial response, if
+	// any.  A nil response means none is sent; an empty one is sent
+	// as "=".
+	Start() (mech string, response []byte, err error)
+	// Next returns the response to a challenge from the server.  more
+	// is false when the challenge is additional data sent with
+	// success, in which case the response is ignored.
+	Next(challenge []byte, more bool) ([]byte, error)
+}
+
+// PlainAuth returns a SASLClient implementing PLAIN (RFC 4616).
+// identity is normally empty, to act as user.
+func PlainAuth(identity, user, pass string) SASLClient {
+	return &plainAuth{identity, user, pass}
+}
+
+type plainAuth struct {
+	identity, user, pass string
+}
+
+func (a *plainAuth) Start() (string, []byte, error) {
+	return "PLAIN", []byte(a.identity + "\x00" + a.user + "\x00" + a.pass), nil
+}
+
+func (a *plainAuth) Next(challenge []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("unexpected challenge")
+	}
+	return nil, nil
+}
+
+// The longest AUTHINFO SASL command allowed with an initial response,
+// not counting CRLF.
+const maxSASLLine = 497
+
+// AuthenticateSASL authenticates with AUTHINFO SASL.
+//
+// See https://datatracker.ietf.org/doc/html/rfc4643#section-2.4
+func (c *Client) AuthenticateSASL(a SASLClient) (msg string, err error) {
+	return c.AuthenticateSASLContext(context.Background(), a)
+}
+
+// AuthenticateSASLContext is AuthenticateSASL with a context.
+func (c *Client) AuthenticateSASLContext(ctx context.Context,
+	a SASLClient) (msg string, err error) {
+
+	mech, response, err := a.Start()
+	if err != nil {
+		return "", err
+	}
+	err = c.do(ctx, func() error {
+		cmd := "AUTHINFO SASL " + mech
+		if response != nil {
+			withResponse := cmd + " " + encodeSASL(response)
+			if len(withResponse) <= maxSASLLine {
+				cmd, response = withResponse, nil
+			}
+		}
+		if err := c.conn.PrintfLine("%s", cmd); err != nil {
+			return err
+		}
+		for {
+			var code int
+			code, msg, err = c.readCodeLine(0)
+			if err != nil {
+				return err
+			}
+			switch code {
+			case 281:
+				return nil
+			case 283:
+				data, err := decodeSASL(msg)
+				if err != nil {
+					return err
+				}
+				_, err = a.Next(data, false)
+				return err
+			case 383:
+			default:
+				return &nntp.Error{Code: code, Msg: msg}
+			}
+
+			if response == nil {
+				challenge, err := decodeSASL(msg)
+				if err == nil {
+					response, err = a.Next(challenge, true)
+				}
+				if err != nil {
+					// Cancel the exchange.
+					if err := c.conn.PrintfLine("*"); err != nil {
+						return err
+					}
+					c.readCodeLine(0)
+					return err
+				}
+			}
+			if err := c.conn.PrintfLine("%s", encodeSASL(response)); err != nil {
+				return err
+			}
+			response = nil
+		}
+	})
+	return
+}
+
+func encodeSASL(b []byte) string {
+	if len(b) == 0 {
+		return "="
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// decodeSASL decodes the challenge from a 283 or 383 response.
+func decodeSASL(msg string) ([]byte, error) {
+	msg = strings.TrimSpace(msg)
+	if i := strings.IndexByte(msg, ' '); i != -1 {
+		msg = msg[:i]
+	}
+	if msg == "" || msg == "=" {
+		return []byte{}, nil
+	}
+	return base64.StdEncoding.DecodeString(msg)
+}
@@ -0,0 +1,45 @@
+package nntp
+
+import (
+	"compress/flate"
+	"io"
+	"net"
+)
+
+// NewDeflateConn wraps c so that everything sent and received is
+// compressed with DEFLATE, as negotiated by COMPRESS DEFLATE (RFC
+// 8054).  Each write is flushed so commands and responses are never
+// held back in the compressor.
+func NewDeflateConn(c net.Conn) net.Conn {
+	w, _ := flate.NewWriter(c, flate.DefaultCompression)
+	return &deflateConn{
+		Conn: c,
+		r:    flate.NewReader(c),
+		w:    w,
+	}
+}
+
+type deflateConn struct {
+	net.Conn
+	r io.ReadCloser
+	w *flate.Writer
+}
+
+func (d *deflateConn) Read(p []byte) (int, error) {
+	return d.r.Read(p)
+}
+
+func (d *deflateConn) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, d.w.Flush()
+}
+
+// Close closes the underlying connection.  Every write has already
+// been flushed, so there's nothing more to send.
+func (d *deflateConn) Close() error {
+	d.r.Close()
+	return d.Conn.Close()
+}
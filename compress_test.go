@@ -0,0 +1,35 @@
+package nntp
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+func TestDeflateConn(t *testing.T) {
+	a, b := net.Pipe()
+	ca, cb := NewDeflateConn(a), NewDeflateConn(b)
+	defer ca.Close()
+	defer cb.Close()
+
+	go func() {
+		// Echo a line back.
+		line, err := bufio.NewReader(cb).ReadString('\n')
+		if err != nil {
+			t.Errorf("Error reading: %v", err)
+			return
+		}
+		cb.Write([]byte(line))
+	}()
+
+	if _, err := ca.Write([]byte("OVER 1-100\r\n")); err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+	line, err := bufio.NewReader(ca).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Error reading echo: %v", err)
+	}
+	if line != "OVER 1-100\r\n" {
+		t.Fatalf("Expected echo, got %q", line)
+	}
+}
@@ -0,0 +1,100 @@
+package nntp
+
+import "fmt"
+
+// An Error is a coded NNTP error response.
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Msg)
+}
+
+// Is reports whether target is an *Error with the same code, so that
+// errors read from a server match the values below with errors.Is
+// regardless of the server's wording.  The values below only match
+// themselves, though, so those sharing a code, such as
+// ErrAccessDenied and ErrCommandUnavailable, can be told apart.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if e == t {
+		return true
+	}
+	return t.Code == e.Code && !(sentinels[e] && sentinels[t])
+}
+
+// The errors declared below.
+var sentinels = map[*Error]bool{}
+
+func sentinel(code int, msg string) *Error {
+	e := &Error{code, msg}
+	sentinels[e] = true
+	return e
+}
+
+// ErrNoSuchGroup is returned for a request for a group that can't be found.
+var ErrNoSuchGroup = sentinel(411, "No such newsgroup")
+
+// ErrNoGroupSelected is returned for a request that requires a current
+// group when none has been selected.
+var ErrNoGroupSelected = sentinel(412, "No newsgroup selected")
+
+// ErrInvalidMessageID is returned when a message is requested that can't be found.
+var ErrInvalidMessageID = sentinel(430, "No article with that message-id")
+
+// ErrNoSuchArticle is an alias for ErrInvalidMessageID.
+var ErrNoSuchArticle = ErrInvalidMessageID
+
+// ErrInvalidArticleNumber is returned when an article is requested that can't be found.
+var ErrInvalidArticleNumber = sentinel(423, "No article with that number")
+
+// ErrNoCurrentArticle is returned when a command is executed that
+// requires a current article when one has not been selected.
+var ErrNoCurrentArticle = sentinel(420, "Current article number is invalid")
+
+// ErrUnknownCommand is returned for unknown comands.
+var ErrUnknownCommand = sentinel(500, "Unknown command")
+
+// ErrSyntax is returned when a command can't be parsed.
+var ErrSyntax = sentinel(501, "not supported, or syntax error")
+
+// ErrPostingNotPermitted is returned as the response to an attempt to
+// post an article where posting is not permitted.
+var ErrPostingNotPermitted = sentinel(440, "Posting not permitted")
+
+// ErrPostingFailed is returned when an attempt to post an article fails.
+var ErrPostingFailed = sentinel(441, "posting failed")
+
+// ErrNotWanted is returned when an attempt to post an article is
+// rejected due the server not wanting the article.
+var ErrNotWanted = sentinel(435, "Article not wanted")
+
+// ErrAuthRequired is returned to indicate authentication is required
+// to proceed.
+var ErrAuthRequired = sentinel(450, "authorization required")
+
+// ErrAuthRejected is returned for invalid authentication.
+var ErrAuthRejected = sentinel(481, "Authentication failed")
+
+// ErrNotAuthenticated is returned when a command is issued that requires
+// authentication, but authentication was not provided.
+var ErrNotAuthenticated = sentinel(480, "authentication required")
+
+// ErrAuthOutOfSequence is returned for AUTHINFO PASS without a
+// preceding AUTHINFO USER.
+var ErrAuthOutOfSequence = sentinel(482, "Authentication commands issued out of sequence")
+
+// ErrSASLProtocol is returned when a SASL exchange is malformed.
+var ErrSASLProtocol = sentinel(482, "SASL protocol error")
+
+// ErrCommandUnavailable is returned for a command that's recognized
+// but not available in the session's current state.
+var ErrCommandUnavailable = sentinel(502, "Command unavailable")
+
+// ErrAccessDenied is returned when access to a group is refused.
+var ErrAccessDenied = sentinel(502, "Access denied")
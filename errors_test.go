@@ -0,0 +1,36 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	err := fmt.Errorf("fetching: %w", &Error{430, "gone fishing"})
+	if !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("Expected %v to match %v", err, ErrNoSuchArticle)
+	}
+	if errors.Is(err, ErrInvalidArticleNumber) {
+		t.Errorf("Expected %v not to match %v", err, ErrInvalidArticleNumber)
+	}
+	if errors.Is(err, errors.New("430 gone fishing")) {
+		t.Errorf("Expected %v not to match a plain error", err)
+	}
+}
+
+func TestErrorIsSentinels(t *testing.T) {
+	if errors.Is(ErrAccessDenied, ErrCommandUnavailable) {
+		t.Errorf("Expected sentinels sharing code 502 to differ")
+	}
+	if !errors.Is(fmt.Errorf("wrapped: %w", ErrAccessDenied), ErrAccessDenied) {
+		t.Errorf("Expected a sentinel to match itself")
+	}
+	wire := &Error{502, "Access denied"}
+	if !errors.Is(wire, ErrAccessDenied) || !errors.Is(wire, ErrCommandUnavailable) {
+		t.Errorf("Expected an error read from a server to match by code")
+	}
+	if !errors.Is(ErrAccessDenied, wire) {
+		t.Errorf("Expected a sentinel to match an error read from a server")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch"
+	"github.com/dustin/go-nntp/backendtest"
+	"github.com/dustin/go-nntp/server"
+)
+
+// COUCH_TEST_URL is a CouchDB server, such as http://localhost:5984/,
+// where a scratch database is made for each test.
+func TestBackend(t *testing.T) {
+	couchURL := os.Getenv("COUCH_TEST_URL")
+	if couchURL == "" {
+		t.Skip("COUCH_TEST_URL not set")
+	}
+	u, err := url.Parse(couchURL)
+	if err != nil {
+		t.Fatalf("Error parsing COUCH_TEST_URL: %v", err)
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	backendtest.Run(t, func(t *testing.T, groups ...string) nntpserver.Backend {
+		name := fmt.Sprintf("nntp_backendtest_%d", time.Now().UnixNano())
+		db, err := couch.NewDatabase(host, port, name)
+		if err != nil {
+			t.Fatalf("Error creating database %v: %v", name, err)
+		}
+		t.Cleanup(func() { db.DeleteDatabase() })
+		if err := ensureViews(&db); err != nil {
+			t.Fatalf("Error setting up views: %v", err)
+		}
+		for _, g := range groups {
+			_, _, err := db.Insert(map[string]string{
+				"_id":         g,
+				"type":        "group",
+				"description": "Test group " + strings.TrimPrefix(g, "backendtest."),
+			})
+			if err != nil {
+				t.Fatalf("Error creating group %v: %v", g, err)
+			}
+		}
+		return &couchBackend{db: &db}
+	})
+}
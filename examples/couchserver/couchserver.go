@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"log/syslog"
+	"log/slog"
 	"net"
+	"net/http"
 	"net/textproto"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +32,25 @@ var optimisticPost = flag.Bool("optimistic", false,
 	"Optimistically return success on store before storing")
 var useSyslog = flag.Bool("syslog", false,
 	"Log to syslog")
+var logLevel slog.Level
+
+func init() {
+	flag.TextVar(&logLevel, "loglevel", slog.LevelInfo,
+		"Minimum level to log (DEBUG, INFO, WARN or ERROR)")
+}
+
+var pathIdentity = flag.String("pathhost", "",
+	"Path identity for injected articles (default: hostname)")
+var htpasswd = flag.String("htpasswd", "",
+	"Require users listed in this htpasswd file to log in")
+var metricsAddr = flag.String("metrics", "",
+	"Serve metrics over HTTP at this address")
+var proxies = flag.String("proxies", "",
+	"Comma separated load balancers sending PROXY protocol headers")
+var maxSessions = flag.Int("maxsessions", 0,
+	"Maximum concurrent sessions (0 for no limit)")
+var maxHostSessions = flag.Int("maxhostsessions", 0,
+	"Maximum concurrent sessions per host (0 for no limit)")
 
 type groupRow struct {
 	Group string        `json:"key"`
@@ -64,6 +85,7 @@ type article struct {
 	MsgID       string                 `json:"_id"`
 	DocType     string                 `json:"type"`
 	Headers     map[string][]string    `json:"headers"`
+	RawHeaders  string                 `json:"raw_headers,omitempty"`
 	Bytes       int                    `json:"bytes"`
 	Lines       int                    `json:"lines"`
 	Nums        map[string]int64       `json:"nums"`
@@ -160,17 +182,25 @@ func (cb *couchBackend) GetGroup(name string) (*nntp.Group, error) {
 
 func (cb *couchBackend) mkArticle(ar article) *nntp.Article {
 	url := fmt.Sprintf("%s/%s/article", cb.db.DBURL(), cleanupID(ar.MsgID, true))
-	return &nntp.Article{
+	rv := &nntp.Article{
 		Header: textproto.MIMEHeader(ar.Headers),
 		Body:   &lazyOpener{url, nil, nil},
 		Bytes:  ar.Bytes,
 		Lines:  ar.Lines,
 	}
+	if ar.RawHeaders != "" {
+		rv.RawHeader = []byte(ar.RawHeaders)
+	}
+	return rv
 }
 
 func (cb *couchBackend) GetArticle(group *nntp.Group, id string) (*nntp.Article, error) {
 	var ar article
-	if intid, err := strconv.ParseInt(id, 10, 64); err == nil {
+	if !nntp.ValidMessageID(id) {
+		intid, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, nntpserver.ErrInvalidArticleNumber
+		}
 		results := articleResults{}
 		cb.db.Query("_design/articles/_view/list", map[string]interface{}{
 			"include_docs": true,
@@ -221,9 +251,10 @@ func (cb *couchBackend) AllowPost() bool {
 }
 
 func cleanupID(msgid string, escapedAt bool) string {
-	s := strings.TrimFunc(msgid, func(r rune) bool {
-		return r == ' ' || r == '<' || r == '>'
-	})
+	s := strings.TrimSpace(msgid)
+	if id, err := nntp.ParseMessageID(s); err == nil {
+		s = id[1 : len(id)-1]
+	}
 	qe := url.QueryEscape(s)
 	if escapedAt {
 		return qe
@@ -235,6 +266,7 @@ func (cb *couchBackend) Post(art *nntp.Article) error {
 	a := article{
 		DocType:     "article",
 		Headers:     map[string][]string(art.Header),
+		RawHeaders:  string(art.RawHeader),
 		Nums:        make(map[string]int64),
 		MsgID:       cleanupID(art.Header.Get("Message-Id"), false),
 		Attachments: make(map[string]*attachment),
@@ -255,12 +287,14 @@ func (cb *couchBackend) Post(art *nntp.Article) error {
 
 	a.Attachments["article"] = &attachment{"text/plain", b}
 
+	var groups []*nntp.Group
 	for _, g := range strings.Split(art.Header.Get("Newsgroups"), ",") {
 		g = strings.TrimSpace(g)
 		group, err := cb.GetGroup(g)
 		if err == nil {
 			a.Nums[g] = atomic.AddInt64(&group.High, 1)
 			atomic.AddInt64(&group.Count, 1)
+			groups = append(groups, group)
 		} else {
 			log.Printf("Error getting group %q:  %v", g, err)
 		}
@@ -283,6 +317,11 @@ func (cb *couchBackend) Post(art *nntp.Article) error {
 		_, _, err = cb.db.Insert(&a)
 		if err != nil {
 			log.Printf("error posting article: %v", err)
+			// The numbers are left unused, but the counts shouldn't
+			// include the article.
+			for _, group := range groups {
+				atomic.AddInt64(&group.Count, -1)
+			}
 			return nntpserver.ErrPostingFailed
 		}
 	}
@@ -290,14 +329,6 @@ func (cb *couchBackend) Post(art *nntp.Article) error {
 	return nil
 }
 
-func (cb *couchBackend) Authorized() bool {
-	return true
-}
-
-func (cb *couchBackend) Authenticate(user, pass string) (nntpserver.Backend, error) {
-	return nil, nntpserver.ErrAuthRejected
-}
-
 func maybefatal(err error, f string, a ...interface{}) {
 	if err != nil {
 		log.Fatalf(f, a...)
@@ -311,12 +342,14 @@ func main() {
 	flag.Parse()
 
 	if *useSyslog {
-		sl, err := syslog.New(syslog.LOG_INFO, "nntpd")
+		logger, err := nntpserver.NewSyslogLogger("nntpd", logLevel)
 		if err != nil {
 			log.Fatalf("Error initializing syslog: %v", err)
 		}
-		log.SetOutput(sl)
-		log.SetFlags(0)
+		slog.SetDefault(logger)
+	} else {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr,
+			&slog.HandlerOptions{Level: logLevel})))
 	}
 
 	a, err := net.ResolveTCPAddr("tcp", ":1119")
@@ -334,11 +367,33 @@ func main() {
 		db: &db,
 	}
 
-	s := nntpserver.NewServer(&backend)
+	if *pathIdentity == "" {
+		*pathIdentity, err = os.Hostname()
+		maybefatal(err, "Error getting hostname: %v", err)
+	}
 
-	for {
-		c, err := l.AcceptTCP()
-		maybefatal(err, "Error accepting connection: %v", err)
-		go s.Process(c)
+	s := nntpserver.NewServer(&backend)
+	s.Validator = &nntpserver.Validator{PathIdentity: *pathIdentity}
+	s.MaxSessions = *maxSessions
+	s.MaxHostSessions = *maxHostSessions
+	if *proxies != "" {
+		s.ProxyProtocol, err = nntpserver.ParseNetworks(
+			strings.Split(*proxies, ",")...)
+		maybefatal(err, "Error parsing proxies: %v", err)
 	}
+	if *htpasswd != "" {
+		users, err := nntpserver.LoadHtpasswd(*htpasswd)
+		maybefatal(err, "Error loading htpasswd: %v", err)
+		s.Authenticator = users
+	}
+	if *metricsAddr != "" {
+		m := nntpserver.NewMetricsRegistry()
+		s.Metrics = m
+		go func() {
+			log.Fatal(http.ListenAndServe(*metricsAddr, m))
+		}()
+	}
+
+	err = s.Serve(l)
+	maybefatal(err, "Error accepting connection: %v", err)
 }
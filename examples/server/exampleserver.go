@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"net/textproto"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -30,6 +31,7 @@ type groupStorage struct {
 
 type articleStorage struct {
 	headers  textproto.MIMEHeader
+	raw      []byte
 	body     string
 	refcount int
 }
@@ -41,29 +43,30 @@ type testBackendType struct {
 	articles map[string]*articleStorage
 }
 
-var testBackend = testBackendType{
-	groups:   map[string]*groupStorage{},
-	articles: map[string]*articleStorage{},
-}
-
-func init() {
+var testBackend = newTestBackend()
 
-	testBackend.groups["alt.test"] = &groupStorage{
-		group: &nntp.Group{
-			Name:        "alt.test",
-			Description: "A test.",
-			Posting:     nntp.PostingNotPermitted},
-		articles: ring.New(maxArticles),
+func newTestBackend() *testBackendType {
+	return &testBackendType{
+		groups:   map[string]*groupStorage{},
+		articles: map[string]*articleStorage{},
 	}
+}
+
+func (tb *testBackendType) addGroup(name, description string,
+	posting nntp.PostingStatus) {
 
-	testBackend.groups["misc.test"] = &groupStorage{
+	tb.groups[name] = &groupStorage{
 		group: &nntp.Group{
-			Name:        "misc.test",
-			Description: "More testing.",
-			Posting:     nntp.PostingPermitted},
+			Name:        name,
+			Description: description,
+			Posting:     posting},
 		articles: ring.New(maxArticles),
 	}
+}
 
+func init() {
+	testBackend.addGroup("alt.test", "A test.", nntp.PostingNotPermitted)
+	testBackend.addGroup("misc.test", "More testing.", nntp.PostingPermitted)
 }
 
 func (tb *testBackendType) ListGroups(max int) ([]*nntp.Group, error) {
@@ -93,10 +96,11 @@ func (tb *testBackendType) GetGroup(name string) (*nntp.Group, error) {
 
 func mkArticle(a *articleStorage) *nntp.Article {
 	return &nntp.Article{
-		Header: a.headers,
-		Body:   strings.NewReader(a.body),
-		Bytes:  len(a.body),
-		Lines:  strings.Count(a.body, "\n"),
+		Header:    a.headers,
+		RawHeader: a.raw,
+		Body:      strings.NewReader(a.body),
+		Bytes:     len(a.body),
+		Lines:     strings.Count(a.body, "\n"),
 	}
 }
 
@@ -117,7 +121,11 @@ func (tb *testBackendType) GetArticle(group *nntp.Group, id string) (*nntp.Artic
 	msgID := id
 	var a *articleStorage
 
-	if intid, err := strconv.ParseInt(id, 10, 64); err == nil {
+	if !nntp.ValidMessageID(id) {
+		intid, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, nntpserver.ErrInvalidArticleNumber
+		}
 		msgID = ""
 		// by int ID.  Gotta go find it.
 		if groupStorage, ok := tb.groups[group.Name]; ok {
@@ -130,6 +138,9 @@ func (tb *testBackendType) GetArticle(group *nntp.Group, id string) (*nntp.Artic
 				}
 				return false
 			})
+			if r == nil {
+				return nil, nntpserver.ErrInvalidArticleNumber
+			}
 			if aref, ok := r.Value.(articleRef); ok {
 				msgID = aref.msgid
 			}
@@ -218,22 +229,29 @@ func (tb *testBackendType) Post(article *nntp.Article) error {
 
 	a := articleStorage{
 		headers:  article.Header,
+		raw:      article.RawHeader,
 		body:     buf.String(),
 		refcount: 0,
 	}
 
 	msgID := a.headers.Get("Message-Id")
 
+	if msgID == "" {
+		return nntpserver.ErrPostingFailed
+	}
+
 	if _, ok := tb.articles[msgID]; ok {
 		return nntpserver.ErrPostingFailed
 	}
 
-	for _, g := range article.Header["Newsgroups"] {
-		if g, ok := tb.groups[g]; ok {
+	for _, g := range strings.Split(article.Header.Get("Newsgroups"), ",") {
+		if g, ok := tb.groups[strings.TrimSpace(g)]; ok {
 			g.articles = g.articles.Next()
 			if g.articles.Value != nil {
 				aref := g.articles.Value.(articleRef)
 				tb.decr(aref.msgid)
+			} else {
+				g.group.Count++
 			}
 			if g.articles.Value != nil || g.group.Low == 0 {
 				g.group.Low++
@@ -245,7 +263,6 @@ func (tb *testBackendType) Post(article *nntp.Article) error {
 			}
 			log.Printf("Placed %v", g.articles.Value)
 			a.refcount++
-			g.group.Count = int64(g.articles.Len())
 
 			log.Printf("Stored %v in %v", msgID, g.group.Name)
 		}
@@ -260,14 +277,6 @@ func (tb *testBackendType) Post(article *nntp.Article) error {
 	return nil
 }
 
-func (tb *testBackendType) Authorized() bool {
-	return true
-}
-
-func (tb *testBackendType) Authenticate(user, pass string) (nntpserver.Backend, error) {
-	return nil, nntpserver.ErrAuthRejected
-}
-
 func maybefatal(err error, f string, a ...interface{}) {
 	if err != nil {
 		log.Fatalf(f, a...)
@@ -281,11 +290,15 @@ func main() {
 	maybefatal(err, "Error setting up listener: %v", err)
 	defer l.Close()
 
-	s := nntpserver.NewServer(&testBackend)
+	hostname, err := os.Hostname()
+	maybefatal(err, "Error getting hostname: %v", err)
 
-	for {
-		c, err := l.AcceptTCP()
-		maybefatal(err, "Error accepting connection: %v", err)
-		go s.Process(c)
+	s := nntpserver.NewServer(testBackend)
+	s.Validator = &nntpserver.Validator{
+		PathIdentity:  hostname,
+		MaxCrossposts: 5,
 	}
+
+	err = s.Serve(l)
+	maybefatal(err, "Error accepting connection: %v", err)
 }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/backendtest"
+	"github.com/dustin/go-nntp/server"
+)
+
+func TestBackend(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T, groups ...string) nntpserver.Backend {
+		tb := newTestBackend()
+		for _, g := range groups {
+			tb.addGroup(g, "Test group", nntp.PostingPermitted)
+		}
+		return tb
+	})
+}
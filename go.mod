@@ -1,8 +1,7 @@
 module github.com/dustin/go-nntp
 
-go 1.16
+go 1.21
 
-require (
-	github.com/dustin/go-couch v0.0.0-20160816170231-8251128dab73
-	github.com/dustin/httputil v0.0.0-20170305193905-c47743f54f89 // indirect
-)
+require github.com/dustin/go-couch v0.0.0-20160816170231-8251128dab73
+
+require github.com/dustin/httputil v0.0.0-20170305193905-c47743f54f89 // indirect
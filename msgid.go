@@ -0,0 +1,102 @@
+package nntp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMalformedMessageID is returned when parsing a message-id that
+// doesn't conform to RFC 5536.
+var ErrMalformedMessageID = errors.New("malformed message-id")
+
+var msgIDCounter uint32
+
+// NewMessageID generates a unique message-id within domain.
+//
+// If domain is empty, the local hostname is used.
+func NewMessageID(domain string) string {
+	if domain == "" {
+		var err error
+		domain, err = os.Hostname()
+		if err != nil || !isDotAtom(domain) {
+			domain = "localhost.invalid"
+		}
+	}
+	var b [6]byte
+	rand.Read(b[:])
+	return "<" + strconv.FormatInt(time.Now().UnixNano(), 36) + "." +
+		strconv.FormatUint(uint64(atomic.AddUint32(&msgIDCounter, 1)), 36) +
+		"." + hex.EncodeToString(b[:]) + "@" + domain + ">"
+}
+
+// ParseMessageID checks that s is a message-id as defined in RFC 5536
+// section 3.1.3 and returns it with surrounding whitespace removed.
+func ParseMessageID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 5 || len(s) > 250 || s[0] != '<' || s[len(s)-1] != '>' {
+		return "", ErrMalformedMessageID
+	}
+	inner := s[1 : len(s)-1]
+	i := strings.LastIndexByte(inner, '@')
+	if i < 0 || !isDotAtom(inner[:i]) {
+		return "", ErrMalformedMessageID
+	}
+	right := inner[i+1:]
+	if !isDotAtom(right) && !isNoFoldLiteral(right) {
+		return "", ErrMalformedMessageID
+	}
+	return s, nil
+}
+
+// ValidMessageID reports whether s is a valid message-id.
+func ValidMessageID(s string) bool {
+	_, err := ParseMessageID(s)
+	return err == nil
+}
+
+func isAtext(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// isDotAtom reports whether s is a dot-atom-text (RFC 5322).
+func isDotAtom(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for i := 0; i < len(atom); i++ {
+			if !isAtext(atom[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isNoFoldLiteral reports whether s is a bracketed no-fold-literal,
+// excluding the characters RFC 5536 forbids in message-ids.
+func isNoFoldLiteral(s string) bool {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || c == '[' || c == ']' || c == '\\' || c == '>' {
+			return false
+		}
+	}
+	return true
+}
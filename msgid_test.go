@@ -0,0 +1,48 @@
+package nntp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidMessageID(t *testing.T) {
+	tests := map[string]bool{
+		"<1234@example.com>":                   true,
+		" <a.b+c@example.com> ":                true,
+		"<x@[192.0.2.1]>":                      true,
+		"<weird!#$%@example>":                  true,
+		"1234@example.com":                     false,
+		"<1234>":                               false,
+		"<@example.com>":                       false,
+		"<a..b@example.com>":                   false,
+		"<a b@example.com>":                    false,
+		"<a@exa>mple.com>":                     false,
+		"<a@[bad\\literal]>":                   false,
+		"<" + strings.Repeat("a", 250) + "@x>": false,
+	}
+	for in, exp := range tests {
+		if got := ValidMessageID(in); got != exp {
+			t.Errorf("ValidMessageID(%q) = %v, want %v", in, got, exp)
+		}
+	}
+}
+
+func TestNewMessageID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := NewMessageID("example.com")
+		if !ValidMessageID(id) {
+			t.Fatalf("Generated invalid message-id: %q", id)
+		}
+		if !strings.HasSuffix(id, "@example.com>") {
+			t.Fatalf("Generated message-id in wrong domain: %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("Generated duplicate message-id: %q", id)
+		}
+		seen[id] = true
+	}
+	if id := NewMessageID(""); !ValidMessageID(id) {
+		t.Errorf("Generated invalid message-id with default domain: %q", id)
+	}
+}
@@ -36,12 +36,19 @@ type Group struct {
 type Article struct {
 	// The article's headers
 	Header textproto.MIMEHeader
+	// The article's header block exactly as received, if known.
+	// When set, it is written verbatim instead of Header, so code that
+	// modifies Header should use AddHeader or clear RawHeader.
+	RawHeader []byte
 	// The article's body
 	Body io.Reader
 	// Number of bytes in the article body (used by OVER/XOVER)
 	Bytes int
 	// Number of lines in the article body (used by OVER/XOVER)
 	Lines int
+
+	// Header names in the order they were read, see ReadArticle.
+	order []string
 }
 
 // MessageID provides convenient access to the article's Message ID.
@@ -0,0 +1,206 @@
+package nntptest
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/server"
+)
+
+// Backend is an nntpserver.Backend keeping groups and articles in
+// memory.  It's safe for concurrent use.
+type Backend struct {
+	// ReadOnly refuses posting.
+	ReadOnly bool
+
+	mu     sync.Mutex
+	groups map[string]*group
+	// Articles by message-id, in wire format.
+	articles map[string][]byte
+}
+
+type group struct {
+	info nntp.Group
+	// Message-ids by article number.
+	numbers map[int64]string
+}
+
+// NewBackend makes an empty Backend.
+func NewBackend() *Backend {
+	return &Backend{
+		groups:   map[string]*group{},
+		articles: map[string][]byte{},
+	}
+}
+
+// AddGroup creates a group, or updates the description and posting
+// status of an existing one.  New groups are empty, with a low water
+// mark of 1 and a high water mark of 0.
+func (b *Backend) AddGroup(name, description string, posting nntp.PostingStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g := b.groups[name]
+	if g == nil {
+		g = &group{
+			info:    nntp.Group{Name: name, Low: 1},
+			numbers: map[int64]string{},
+		}
+		b.groups[name] = g
+	}
+	g.info.Description = description
+	g.info.Posting = posting
+}
+
+// AddArticle parses an article in wire format and stores it as if it
+// had been posted, returning its message-id.
+func (b *Backend) AddArticle(text string) (string, error) {
+	a, err := nntp.ReadArticle(strings.NewReader(text))
+	if err != nil {
+		return "", err
+	}
+	return a.MessageID(), b.store(a)
+}
+
+// ListGroups returns up to max groups, or all of them if max is
+// negative, sorted by name.
+func (b *Backend) ListGroups(max int) ([]*nntp.Group, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.groups))
+	for name := range b.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if max >= 0 && len(names) > max {
+		names = names[:max]
+	}
+	rv := make([]*nntp.Group, 0, len(names))
+	for _, name := range names {
+		g := b.groups[name].info
+		rv = append(rv, &g)
+	}
+	return rv, nil
+}
+
+// GetGroup returns a snapshot of the named group.
+func (b *Backend) GetGroup(name string) (*nntp.Group, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g, ok := b.groups[name]
+	if !ok {
+		return nil, nntpserver.ErrNoSuchGroup
+	}
+	info := g.info
+	return &info, nil
+}
+
+// GetArticle finds an article by message-id or by number in group.
+func (b *Backend) GetArticle(grp *nntp.Group, id string) (*nntp.Article, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if nntp.ValidMessageID(id) {
+		return b.article(id, nntpserver.ErrInvalidMessageID)
+	}
+	if grp == nil {
+		return nil, nntpserver.ErrNoGroupSelected
+	}
+	num, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, nntpserver.ErrInvalidArticleNumber
+	}
+	g, ok := b.groups[grp.Name]
+	if !ok {
+		return nil, nntpserver.ErrNoSuchGroup
+	}
+	return b.article(g.numbers[num], nntpserver.ErrInvalidArticleNumber)
+}
+
+// article parses the stored article with the given message-id, or
+// returns notFound.
+func (b *Backend) article(msgid string, notFound error) (*nntp.Article, error) {
+	raw, ok := b.articles[msgid]
+	if !ok {
+		return nil, notFound
+	}
+	return nntp.ReadArticle(bytes.NewReader(raw))
+}
+
+// GetArticles returns the articles numbered from to to in group, in
+// order.
+func (b *Backend) GetArticles(grp *nntp.Group, from, to int64) ([]nntpserver.NumberedArticle, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g, ok := b.groups[grp.Name]
+	if !ok {
+		return nil, nntpserver.ErrNoSuchGroup
+	}
+	var nums []int64
+	for n := range g.numbers {
+		if n >= from && n <= to {
+			nums = append(nums, n)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	rv := make([]nntpserver.NumberedArticle, 0, len(nums))
+	for _, n := range nums {
+		a, err := b.article(g.numbers[n], nntpserver.ErrInvalidArticleNumber)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, nntpserver.NumberedArticle{Num: n, Article: a})
+	}
+	return rv, nil
+}
+
+// AllowPost reports whether posting is allowed.
+func (b *Backend) AllowPost() bool {
+	return !b.ReadOnly
+}
+
+// Post stores an article in each of its known newsgroups.
+func (b *Backend) Post(article *nntp.Article) error {
+	if b.ReadOnly {
+		return nntpserver.ErrPostingNotPermitted
+	}
+	return b.store(article)
+}
+
+func (b *Backend) store(article *nntp.Article) error {
+	var buf bytes.Buffer
+	if _, err := article.WriteTo(&buf); err != nil {
+		return err
+	}
+	msgid := article.MessageID()
+	if msgid == "" {
+		return nntpserver.ErrPostingFailed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, dup := b.articles[msgid]; dup {
+		return nntpserver.ErrPostingFailed
+	}
+	var groups []*group
+	seen := map[string]bool{}
+	for _, name := range strings.Split(article.Header.Get("Newsgroups"), ",") {
+		name = strings.TrimSpace(name)
+		if g, ok := b.groups[name]; ok && !seen[name] {
+			seen[name] = true
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		return nntpserver.ErrPostingFailed
+	}
+	b.articles[msgid] = buf.Bytes()
+	for _, g := range groups {
+		g.info.High++
+		g.info.Count++
+		g.numbers[g.info.High] = msgid
+	}
+	return nil
+}
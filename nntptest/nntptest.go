@@ -0,0 +1,139 @@
+// Package nntptest runs NNTP servers for tests.
+//
+// A Server is an nntpserver.Server listening on the loopback
+// interface, with clients to talk to it:
+//
+//	s := nntptest.NewServer(t, nil)
+//	s.Backend.AddGroup("misc.test", "Testing", nntp.PostingPermitted)
+//	c := s.Client(t)
+//	g, err := c.Group("misc.test")
+//
+// Transcript checks the exact lines exchanged in a session.
+package nntptest
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp/client"
+	"github.com/dustin/go-nntp/server"
+)
+
+// Timeout bounds how long Transcript waits for each response.
+var Timeout = 5 * time.Second
+
+// A Server is a running nntpserver.Server.  Its fields may be changed
+// before connecting, and apply to sessions started afterwards.
+type Server struct {
+	*nntpserver.Server
+	// Backend is the in-memory backend made by NewServer, if it
+	// wasn't given one.
+	Backend *Backend
+	// Addr is the address the server listens on.
+	Addr string
+}
+
+// NewServer starts a server for backend on a loopback port.  If
+// backend is nil, a new Backend is used.  The server is closed when
+// the test finishes.
+func NewServer(tb testing.TB, backend nntpserver.Backend) *Server {
+	tb.Helper()
+	rv := &Server{}
+	if backend == nil {
+		rv.Backend = NewBackend()
+		backend = rv.Backend
+	}
+	rv.Server = nntpserver.NewServer(backend)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("Error listening: %v", err)
+	}
+	tb.Cleanup(func() { l.Close() })
+	rv.Addr = l.Addr().String()
+	go rv.Serve(l)
+	return rv
+}
+
+// Client connects a client to the server.  It's closed when the test
+// finishes.
+func (s *Server) Client(tb testing.TB) *nntpclient.Client {
+	tb.Helper()
+	c, err := nntpclient.New("tcp", s.Addr)
+	if err != nil {
+		tb.Fatalf("Error connecting to %v: %v", s.Addr, err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c
+}
+
+// PipeClient connects a client to the server over net.Pipe rather
+// than the network.  The pipe has no buffering, so commands must not
+// be pipelined.
+func (s *Server) PipeClient(tb testing.TB) *nntpclient.Client {
+	tb.Helper()
+	a, b := net.Pipe()
+	go s.Process(b)
+	c, err := nntpclient.NewConn(a)
+	if err != nil {
+		tb.Fatalf("Error connecting over pipe: %v", err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Transcript runs a session and checks the lines exchanged.  Lines
+// of script starting with "C:" are sent, and those starting with "S:"
+// must match the next line received exactly, including the "." ending
+// a multi-line response.  Leading white space and the space after the
+// colon are dropped, so "C:" alone sends an empty line.  Other lines
+// are ignored, and the greeting isn't checked.
+//
+//	s.Transcript(t, `
+//	C: GROUP misc.test
+//	S: 211 0 1 0 misc.test
+//	C: QUIT
+//	S: 205 bye
+//	`)
+func (s *Server) Transcript(tb testing.TB, script string) {
+	tb.Helper()
+	nc, err := net.Dial("tcp", s.Addr)
+	if err != nil {
+		tb.Fatalf("Error connecting to %v: %v", s.Addr, err)
+	}
+	defer nc.Close()
+	c := textproto.NewConn(nc)
+
+	read := func() string {
+		nc.SetReadDeadline(time.Now().Add(Timeout))
+		line, err := c.ReadLine()
+		if err != nil {
+			tb.Fatalf("Error reading response: %v", err)
+		}
+		return line
+	}
+	read()
+
+	lines := bufio.NewScanner(strings.NewReader(script))
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimLeft(lines.Text(), " \t")
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		text := strings.TrimPrefix(line[2:], " ")
+		switch line[0] {
+		case 'C':
+			if err := c.PrintfLine("%s", text); err != nil {
+				tb.Fatalf("Line %d: error sending %q: %v", n, text, err)
+			}
+		case 'S':
+			if got := read(); got != text {
+				tb.Fatalf("Line %d: expected %q, got %q", n, text, got)
+			}
+		}
+	}
+}
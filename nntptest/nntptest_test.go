@@ -0,0 +1,80 @@
+package nntptest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/client"
+)
+
+const testArticle = "Message-Id: <1@example.com>\r\n" +
+	"Newsgroups: misc.test, alt.test\r\n" +
+	"Subject: hello\r\n" +
+	"\r\n" +
+	"Hello, world.\r\n"
+
+func TestBackend(t *testing.T) {
+	b := NewBackend()
+	b.AddGroup("misc.test", "Testing", nntp.PostingPermitted)
+	b.AddGroup("alt.test", "More testing", nntp.PostingPermitted)
+	if _, err := b.AddArticle(testArticle); err != nil {
+		t.Fatalf("Error adding article: %v", err)
+	}
+	if _, err := b.AddArticle(testArticle); !errors.Is(err, nntp.ErrPostingFailed) {
+		t.Errorf("Expected duplicate to fail, got %v", err)
+	}
+
+	groups, _ := b.ListGroups(-1)
+	if len(groups) != 2 || groups[0].Name != "alt.test" {
+		t.Fatalf("Unexpected groups: %v", groups)
+	}
+	for _, g := range groups {
+		if g.Count != 1 || g.Low != 1 || g.High != 1 {
+			t.Errorf("Unexpected counts for %v: %+v", g.Name, g)
+		}
+		a, err := b.GetArticle(g, "1")
+		if err != nil {
+			t.Fatalf("Error getting %v 1: %v", g.Name, err)
+		}
+		body, _ := io.ReadAll(a.Body)
+		if a.MessageID() != "<1@example.com>" || string(body) != "Hello, world.\r\n" {
+			t.Errorf("Unexpected article %v: %q", a.MessageID(), body)
+		}
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	s := NewServer(t, nil)
+	s.Backend.AddGroup("misc.test", "Testing", nntp.PostingPermitted)
+	s.Backend.AddArticle(strings.Replace(testArticle, ", alt.test", "", 1))
+
+	s.Transcript(t, `
+		C: GROUP misc.test
+		S: 211 1 1 1 misc.test
+		C: BODY 1
+		S: 222 1 <1@example.com>
+		S: Hello, world.
+		S: .
+		C: QUIT
+		S: 205 bye
+	`)
+}
+
+func TestClients(t *testing.T) {
+	s := NewServer(t, nil)
+	s.Backend.AddGroup("misc.test", "Testing", nntp.PostingPermitted)
+	for i, c := range []*nntpclient.Client{s.Client(t), s.PipeClient(t)} {
+		article := strings.Replace(testArticle, "<1@", fmt.Sprintf("<%d@", i), 1)
+		if err := c.Post(strings.NewReader(article)); err != nil {
+			t.Errorf("Error posting: %v", err)
+		}
+	}
+	g, err := s.Backend.GetGroup("misc.test")
+	if err != nil || g.Count != 2 {
+		t.Errorf("Expected two articles posted, got %+v (%v)", g, err)
+	}
+}
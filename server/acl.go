@@ -0,0 +1,148 @@
+package nntpserver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/dustin/go-nntp"
+)
+
+// An ACLRule grants access to the groups matching its wildmats to the
+// sessions it applies to.
+type ACLRule struct {
+	// Users the rule applies to.  Empty means every session,
+	// authenticated or not, and "*" means any authenticated user.
+	Users []string
+	// Networks the rule applies to, by client address.  Empty means
+	// any address.
+	Networks []*net.IPNet
+	// Read and Post are wildmats of the groups that may be read and
+	// posted to.
+	Read string
+	Post string
+}
+
+// An ACL is an ordered list of rules.  The first rule that applies to
+// a session decides its access, and sessions no rule applies to have
+// none.
+type ACL []ACLRule
+
+// ParseNetworks parses CIDR blocks or single addresses for
+// ACLRule.Networks.
+func ParseNetworks(specs ...string) ([]*net.IPNet, error) {
+	rv := make([]*net.IPNet, 0, len(specs))
+	for _, spec := range specs {
+		if !strings.Contains(spec, "/") {
+			ip := net.ParseIP(spec)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", spec)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			rv = append(rv, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(spec)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, n)
+	}
+	return rv, nil
+}
+
+func (r *ACLRule) applies(user string, ip net.IP) bool {
+	if len(r.Users) > 0 {
+		found := false
+		for _, u := range r.Users {
+			if user != "" && (u == "*" || u == user) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Networks) > 0 {
+		found := false
+		for _, n := range r.Networks {
+			if ip != nil && n.Contains(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// rule finds the first rule that applies to user connecting from
+// addr, or nil if none does.
+func (a ACL) rule(user string, addr net.Addr) *ACLRule {
+	ip := net.ParseIP(postingHost(addr))
+	for i := range a {
+		if a[i].applies(user, ip) {
+			return &a[i]
+		}
+	}
+	return nil
+}
+
+// canRead reports whether the session may read group.
+func (s *Session) canRead(group string) bool {
+	if s.server.ACL == nil {
+		return true
+	}
+	r := s.server.ACL.rule(s.user, s.remoteAddr)
+	return r != nil && nntp.MatchWildmat(r.Read, group)
+}
+
+// canPost reports whether the session may post to group.  An empty
+// group asks whether it may post at all.
+func (s *Session) canPost(group string) bool {
+	if s.server.ACL == nil {
+		return true
+	}
+	r := s.server.ACL.rule(s.user, s.remoteAddr)
+	if r == nil || r.Post == "" {
+		return false
+	}
+	return group == "" || nntp.MatchWildmat(r.Post, group)
+}
+
+// accessDenied is the error for a group the session can't read:
+// authenticating might help, otherwise it's refused outright.
+func (s *Session) accessDenied() error {
+	if s.server.Authenticator != nil && !s.authenticated {
+		return ErrNotAuthenticated
+	}
+	return ErrAccessDenied
+}
+
+// canReadArticle reports whether any of an article's groups are
+// readable, for lookups by message-id.
+func (s *Session) canReadArticle(a *nntp.Article) bool {
+	if s.server.ACL == nil {
+		return true
+	}
+	for _, g := range strings.Split(a.Header.Get("Newsgroups"), ",") {
+		if s.canRead(strings.TrimSpace(g)) {
+			return true
+		}
+	}
+	return false
+}
+
+// postingStatus is a group's posting status as seen by the session.
+func (s *Session) postingStatus(g *nntp.Group) nntp.PostingStatus {
+	if g.Posting != nntp.PostingNotPermitted && !s.canPost(g.Name) {
+		return nntp.PostingNotPermitted
+	}
+	return g.Posting
+}
@@ -0,0 +1,68 @@
+package nntpserver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/dustin/go-nntp"
+)
+
+func TestACL(t *testing.T) {
+	lan, err := ParseNetworks("192.0.2.0/24", "2001:db8::1")
+	if err != nil {
+		t.Fatalf("Error parsing networks: %v", err)
+	}
+	srv := &Server{
+		Authenticator: testAuth,
+		ACL: ACL{
+			{Users: []string{"admin"}, Read: "*", Post: "*"},
+			{Users: []string{"*"}, Read: "*,!private.*", Post: "misc.*"},
+			{Networks: lan, Read: "local.*"},
+		},
+	}
+	tests := []struct {
+		user, addr      string
+		group           string
+		read, post, any bool
+	}{
+		{"admin", "198.51.100.1", "private.x", true, true, true},
+		{"bob", "198.51.100.1", "private.x", false, false, true},
+		{"bob", "198.51.100.1", "misc.test", true, true, true},
+		{"bob", "198.51.100.1", "comp.lang.go", true, false, true},
+		{"", "192.0.2.7", "local.news", true, false, false},
+		{"", "192.0.2.7", "misc.test", false, false, false},
+		{"", "[2001:db8::1]", "local.news", true, false, false},
+		{"", "198.51.100.1", "local.news", false, false, false},
+	}
+	for _, test := range tests {
+		addr, _ := net.ResolveTCPAddr("tcp", test.addr+":119")
+		s := &Session{server: srv, user: test.user, remoteAddr: addr,
+			authenticated: test.user != ""}
+		if got := s.canRead(test.group); got != test.read {
+			t.Errorf("%q@%v canRead(%q) = %v", test.user, test.addr, test.group, got)
+		}
+		if got := s.canPost(test.group); got != test.post {
+			t.Errorf("%q@%v canPost(%q) = %v", test.user, test.addr, test.group, got)
+		}
+		if got := s.canPost(""); got != test.any {
+			t.Errorf("%q@%v canPost(\"\") = %v", test.user, test.addr, got)
+		}
+		g := &nntp.Group{Name: test.group, Posting: nntp.PostingPermitted}
+		exp := nntp.PostingNotPermitted
+		if test.post {
+			exp = nntp.PostingPermitted
+		}
+		if got := s.postingStatus(g); got != exp {
+			t.Errorf("%q@%v postingStatus(%q) = %v", test.user, test.addr, test.group, got)
+		}
+	}
+
+	s := &Session{server: srv}
+	if err := s.accessDenied(); err != ErrNotAuthenticated {
+		t.Errorf("Expected 480 before authenticating, got %v", err)
+	}
+	s.authenticated = true
+	if err := s.accessDenied(); err != ErrAccessDenied {
+		t.Errorf("Expected 502 after authenticating, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package nntpserver
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// An Authenticator checks the credentials given with AUTHINFO USER/PASS
+// or SASL PLAIN.  It returns ErrAuthRejected for bad credentials.
+type Authenticator interface {
+	Authenticate(user, pass string) error
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(user, pass string) error
+
+// Authenticate calls f(user, pass).
+func (f AuthenticatorFunc) Authenticate(user, pass string) error {
+	return f(user, pass)
+}
+
+// StaticAuthenticator authenticates against a fixed map of user names
+// to plain text passwords.
+type StaticAuthenticator map[string]string
+
+// Authenticate checks user's password.
+func (m StaticAuthenticator) Authenticate(user, pass string) error {
+	want, ok := m[user]
+	if !ok || subtle.ConstantTimeCompare([]byte(want), []byte(pass)) != 1 {
+		return ErrAuthRejected
+	}
+	return nil
+}
+
+// Htpasswd authenticates against users and password hashes in the
+// format of Apache's htpasswd files.  The {SHA} and $apr1$ (MD5)
+// schemes are supported.
+type Htpasswd map[string]string
+
+// LoadHtpasswd reads an htpasswd file.
+func LoadHtpasswd(path string) (Htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
+
+// ParseHtpasswd reads htpasswd lines of the form "user:hash".  Blank
+// lines and lines starting with # are skipped.
+func ParseHtpasswd(r io.Reader) (Htpasswd, error) {
+	rv := Htpasswd{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("htpasswd line %d: missing ':'", n)
+		}
+		hash := parts[1]
+		if !strings.HasPrefix(hash, "{SHA}") && !strings.HasPrefix(hash, "$apr1$") {
+			return nil, fmt.Errorf("htpasswd line %d: unsupported hash for %q",
+				n, parts[0])
+		}
+		rv[parts[0]] = hash
+	}
+	return rv, s.Err()
+}
+
+// Authenticate checks user's password against its hash.
+func (h Htpasswd) Authenticate(user, pass string) error {
+	hash, ok := h[user]
+	if !ok {
+		return ErrAuthRejected
+	}
+	var got string
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(pass))
+		got = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt := strings.SplitN(hash[len("$apr1$"):], "$", 2)[0]
+		got = apr1(pass, salt)
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(hash)) != 1 {
+		return ErrAuthRejected
+	}
+	return nil
+}
+
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// apr1 is Apache's variant of the MD5-based crypt.
+func apr1(pass, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	alt := md5.Sum([]byte(pass + salt + pass))
+
+	ctx := md5.New()
+	io.WriteString(ctx, pass+"$apr1$"+salt)
+	for i := len(pass); i > 0; i -= 16 {
+		n := i
+		if n > 16 {
+			n = 16
+		}
+		ctx.Write(alt[:n])
+	}
+	for i := len(pass); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write([]byte{pass[0]})
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		ctx := md5.New()
+		if i&1 != 0 {
+			io.WriteString(ctx, pass)
+		} else {
+			ctx.Write(sum)
+		}
+		if i%3 != 0 {
+			io.WriteString(ctx, salt)
+		}
+		if i%7 != 0 {
+			io.WriteString(ctx, pass)
+		}
+		if i&1 != 0 {
+			ctx.Write(sum)
+		} else {
+			io.WriteString(ctx, pass)
+		}
+		sum = ctx.Sum(nil)
+	}
+
+	var out []byte
+	enc := func(a, b, c byte, n int) {
+		v := uint(a)<<16 | uint(b)<<8 | uint(c)
+		for ; n > 0; n-- {
+			out = append(out, apr1Alphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	enc(sum[0], sum[6], sum[12], 4)
+	enc(sum[1], sum[7], sum[13], 4)
+	enc(sum[2], sum[8], sum[14], 4)
+	enc(sum[3], sum[9], sum[15], 4)
+	enc(sum[4], sum[10], sum[5], 4)
+	enc(0, 0, sum[11], 2)
+	return "$apr1$" + salt + "$" + string(out)
+}
+
+// A UserBackend can provide a different Backend for each
+// authenticated user, for example to apply per-user access rules.
+// If the server's Backend implements it, ForUser is called after each
+// successful authentication and the result used for the rest of the
+// session.
+type UserBackend interface {
+	ForUser(user string) (Backend, error)
+}
+
+// login records a successful authentication as user.
+func (s *Session) login(user string) error {
+	if ub, ok := s.backend.(UserBackend); ok {
+		b, err := ub.ForUser(user)
+		if err != nil {
+			return err
+		}
+		s.backend = b
+	}
+	s.user = user
+	s.authenticated = true
+	if s.server.RateByUser || s.server.DailyQuota > 0 {
+		s.limits = s.server.userLimits(user)
+	}
+	s.authUser, s.authPending = "", false
+	s.log().Info("authenticated")
+	return nil
+}
+
+// Commands that manage the session itself, available before
+// authenticating and in any mode.
+var publicCommands = map[string]bool{
+	"":             true,
+	"authinfo":     true,
+	"capabilities": true,
+	"compress":     true,
+	"date":         true,
+	"help":         true,
+	"mode":         true,
+	"quit":         true,
+	"starttls":     true,
+}
+
+// authRequired reports whether cmd has to wait for authentication.
+func (s *Session) authRequired(cmd string) bool {
+	return s.server.Authenticator != nil && !s.authenticated &&
+		!publicCommands[cmd]
+}
@@ -0,0 +1,46 @@
+package nntpserver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHtpasswd(t *testing.T) {
+	h, err := ParseHtpasswd(strings.NewReader(`# users
+sha:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+md5:$apr1$r31.....$G/cElGhD0cboYkZN5h5Ne/
+
+spaced:$apr1$abcdefgh$aJUuGLjz3OI4ylHF//t5U1
+`))
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+	tests := []struct {
+		user, pass string
+		ok         bool
+	}{
+		{"sha", "secret", true},
+		{"sha", "Secret", false},
+		{"md5", "secret", true},
+		{"md5", "secret ", false},
+		{"spaced", "p@ss word", true},
+		{"nobody", "secret", false},
+	}
+	for _, test := range tests {
+		err := h.Authenticate(test.user, test.pass)
+		if (err == nil) != test.ok {
+			t.Errorf("Authenticate(%q, %q) = %v", test.user, test.pass, err)
+		}
+		if err != nil && !errors.Is(err, ErrAuthRejected) {
+			t.Errorf("Expected ErrAuthRejected, got %v", err)
+		}
+	}
+}
+
+func TestHtpasswdUnsupported(t *testing.T) {
+	_, err := ParseHtpasswd(strings.NewReader("u:$2y$05$abcdefghijklmnopqrstuv\n"))
+	if err == nil {
+		t.Fatalf("Expected an error for a bcrypt hash")
+	}
+}
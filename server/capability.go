@@ -0,0 +1,142 @@
+package nntpserver
+
+import (
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// A Capability returns the CAPABILITIES lines a command contributes
+// for a session, if any.
+type Capability func(s *Session) []string
+
+// A CapabilityBackend is a Backend that advertises capabilities of
+// its own, such as for extra commands it supports.
+type CapabilityBackend interface {
+	Capabilities(s *Session) []string
+}
+
+// lines is a Capability that always returns l.
+func lines(l ...string) Capability {
+	return func(*Session) []string {
+		return l
+	}
+}
+
+// defaultCapabilities are the capabilities of the built-in handlers.
+func defaultCapabilities() map[string]Capability {
+	return map[string]Capability{
+		"mode":  modeCapabilities,
+		"check": streamingCapabilities,
+		"post": func(s *Session) []string {
+			if s.backend.AllowPost() && s.canPost("") {
+				return []string{"POST"}
+			}
+			return nil
+		},
+		"ihave": func(s *Session) []string {
+			if s.backend.AllowPost() {
+				return []string{"IHAVE"}
+			}
+			return nil
+		},
+		"over":  lines("OVER"),
+		"xover": lines("XOVER"),
+		"list": func(s *Session) []string {
+			l := "LIST ACTIVE NEWSGROUPS"
+			if _, ok := s.server.Handlers["over"]; ok {
+				l += " OVERVIEW.FMT"
+			}
+			return []string{l}
+		},
+		"authinfo": authCapabilities,
+		"compress": func(s *Session) []string {
+			if s.compressAllowed() {
+				return []string{"COMPRESS DEFLATE"}
+			}
+			return nil
+		},
+		"starttls": func(s *Session) []string {
+			if s.startTLSAllowed() {
+				return []string{"STARTTLS"}
+			}
+			return nil
+		},
+		"xzver": func(s *Session) []string {
+			if s.server.CompressOverview {
+				return []string{"XZVER"}
+			}
+			return nil
+		},
+		"xfeature": func(s *Session) []string {
+			if s.server.CompressOverview {
+				return []string{"XFEATURE-COMPRESS GZIP TERMINATOR"}
+			}
+			return nil
+		},
+	}
+}
+
+func authCapabilities(s *Session) []string {
+	// RFC 4643 forbids advertising AUTHINFO once authenticated.
+	if s.authenticated {
+		return nil
+	}
+	var methods, rv []string
+	if s.server.Authenticator != nil {
+		methods = append(methods, "USER")
+	}
+	mechs := s.server.saslNames()
+	if mechs != "" {
+		methods = append(methods, "SASL")
+	}
+	if len(methods) > 0 {
+		rv = append(rv, "AUTHINFO "+strings.Join(methods, " "))
+	}
+	if mechs != "" {
+		rv = append(rv, "SASL "+mechs)
+	}
+	return rv
+}
+
+// capabilities collects the session's capability lines from the
+// registered handlers and the backend.
+func (s *Session) capabilities() []string {
+	var cmds []string
+	for cmd := range s.server.Capabilities {
+		if _, ok := s.server.Handlers[cmd]; ok && s.modeAllows(cmd) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Strings(cmds)
+
+	rv := []string{"VERSION 2"}
+	seen := map[string]bool{rv[0]: true}
+	add := func(lines []string) {
+		for _, l := range lines {
+			if !seen[l] {
+				seen[l] = true
+				rv = append(rv, l)
+			}
+		}
+	}
+	for _, cmd := range cmds {
+		add(s.server.Capabilities[cmd](s))
+	}
+	if cb, ok := s.backend.(CapabilityBackend); ok {
+		add(cb.Capabilities(s))
+	}
+	return rv
+}
+
+func handleCap(args []string, s *Session, c *textproto.Conn) error {
+	c.PrintfLine("101 Capability list:")
+	dw := c.DotWriter()
+	defer dw.Close()
+
+	for _, l := range s.capabilities() {
+		fmt.Fprintf(dw, "%s\n", l)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package nntpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp"
+)
+
+func testCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func readCaps(t *testing.T, c *textproto.Conn) string {
+	c.PrintfLine("CAPABILITIES")
+	if _, _, err := c.ReadCodeLine(101); err != nil {
+		t.Fatalf("Error requesting capabilities: %v", err)
+	}
+	caps, err := c.ReadDotLines()
+	if err != nil {
+		t.Fatalf("Error reading capabilities: %v", err)
+	}
+	if caps[0] != "VERSION 2" {
+		t.Errorf("Expected VERSION 2 first, got %q", caps)
+	}
+	return strings.Join(caps, "|")
+}
+
+func TestCapabilitiesStartTLS(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	s := NewServer(authBackend{})
+	s.Authenticator = testAuth
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{testCert(t)}}
+	s.Capabilities["xsearch"] = lines("XSEARCH")
+	go s.Process(b)
+
+	c := textproto.NewConn(a)
+	c.ReadCodeLine(200)
+	caps := readCaps(t, c)
+	for _, want := range []string{"STARTTLS", "AUTHINFO USER SASL", "SASL PLAIN"} {
+		if !strings.Contains(caps, want) {
+			t.Errorf("Expected %q before TLS, got %q", want, caps)
+		}
+	}
+	if strings.Contains(caps, "XSEARCH") {
+		t.Errorf("Advertised XSEARCH without a handler: %q", caps)
+	}
+
+	c.PrintfLine("STARTTLS")
+	if _, _, err := c.ReadCodeLine(382); err != nil {
+		t.Fatalf("Error starting TLS: %v", err)
+	}
+	tc := tls.Client(a, &tls.Config{InsecureSkipVerify: true})
+	if err := tc.Handshake(); err != nil {
+		t.Fatalf("Error in TLS handshake: %v", err)
+	}
+	c = textproto.NewConn(tc)
+
+	s.Handlers["xsearch"] = handleDefault
+	caps = readCaps(t, c)
+	if strings.Contains(caps, "STARTTLS") {
+		t.Errorf("Advertised STARTTLS under TLS: %q", caps)
+	}
+	if !strings.Contains(caps, "XSEARCH") {
+		t.Errorf("Expected XSEARCH once handled: %q", caps)
+	}
+	c.PrintfLine("STARTTLS")
+	if code, _, _ := c.ReadCodeLine(0); code != 502 {
+		t.Errorf("Expected 502 for a second STARTTLS, got %d", code)
+	}
+}
+
+// overviewBackend has every group, with no articles.
+type overviewBackend struct {
+	groupBackend
+}
+
+func (overviewBackend) GetArticles(*nntp.Group, int64, int64) ([]NumberedArticle, error) {
+	return nil, nil
+}
+
+func TestStartTLSResetsState(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	s := NewServer(overviewBackend{})
+	s.Mode = ModeSwitching
+	s.CompressOverview = true
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{testCert(t)}}
+	s.Handlers["xcount"] = func(args []string, s *Session, c *textproto.Conn) error {
+		n, _ := s.Values["n"].(int)
+		s.Values["n"] = n + 1
+		return c.PrintfLine("199 %d", n+1)
+	}
+	go s.Process(b)
+
+	c := textproto.NewConn(a)
+	c.ReadCodeLine(200)
+	expect := func(cmd string, code int) {
+		t.Helper()
+		c.PrintfLine("%s", cmd)
+		if got, msg, _ := c.ReadCodeLine(0); got != code {
+			t.Fatalf("%v: expected %d, got %d %s", cmd, code, got, msg)
+		}
+	}
+	expect("MODE READER", 201)
+	expect("GROUP misc.test", 211)
+	expect("XFEATURE COMPRESS GZIP", 290)
+	expect("XCOUNT", 199)
+
+	expect("STARTTLS", 382)
+	tc := tls.Client(a, &tls.Config{InsecureSkipVerify: true})
+	if err := tc.Handshake(); err != nil {
+		t.Fatalf("Error in TLS handshake: %v", err)
+	}
+	c = textproto.NewConn(tc)
+
+	// Back in transit mode.
+	expect("GROUP misc.test", 502)
+	expect("MODE READER", 201)
+	// With no group selected.
+	expect("ARTICLE 1", 412)
+	expect("GROUP misc.test", 211)
+	// With overview uncompressed.
+	expect("XOVER 1-", 224)
+	if lines, err := c.ReadDotLines(); err != nil {
+		t.Errorf("Expected an uncompressed overview, got %q (%v)", lines, err)
+	}
+	// And with new Values.
+	c.PrintfLine("XCOUNT")
+	if _, msg, err := c.ReadCodeLine(199); err != nil || msg != "1" {
+		t.Errorf("Expected Values to be emptied, got %q (%v)", msg, err)
+	}
+}
@@ -0,0 +1,123 @@
+package nntpserver_test
+
+import (
+	"testing"
+
+	"github.com/dustin/go-nntp"
+	"github.com/dustin/go-nntp/nntptest"
+	"github.com/dustin/go-nntp/server"
+)
+
+func newServer(t *testing.T) *nntptest.Server {
+	s := nntptest.NewServer(t, nil)
+	s.Backend.AddGroup("misc.test", "Testing", nntp.PostingPermitted)
+	s.Backend.AddGroup("alt.test", "More testing", nntp.PostingNotPermitted)
+	s.Backend.AddArticle("Message-Id: <1@example.com>\r\n" +
+		"From: test@example.com\r\n" +
+		"Newsgroups: misc.test,alt.test\r\n" +
+		"Subject: hello\r\n" +
+		"\r\n" +
+		".dot-stuffed\r\n")
+	return s
+}
+
+func TestReaderSession(t *testing.T) {
+	newServer(t).Transcript(t, `
+		C: LIST
+		S: 215 list of newsgroups follows
+		S: alt.test 1 1 n
+		S: misc.test 1 1 y
+		S: .
+		C: ARTICLE 1
+		S: 412 No newsgroup selected
+		C: GROUP alt.test
+		S: 211 1 1 1 alt.test
+		C: HEAD
+		S: 221 1 <1@example.com>
+		S: Message-Id: <1@example.com>
+		S: From: test@example.com
+		S: Newsgroups: misc.test,alt.test
+		S: Subject: hello
+		S: .
+		C: BODY 2
+		S: 423 No article with that number
+		C: BODY <1@example.com>
+		S: 222 0 <1@example.com>
+		S: ..dot-stuffed
+		S: .
+		C: BODY <2@example.com>
+		S: 430 No article with that message-id
+		C: QUIT
+		S: 205 bye
+	`)
+}
+
+func TestPostingSession(t *testing.T) {
+	s := newServer(t)
+	s.Validator = &nntpserver.Validator{PathIdentity: "test.example.com"}
+	s.Transcript(t, `
+		C: POST
+		S: 340 Go ahead
+		C: From: test@example.com
+		C: Newsgroups: misc.test
+		C: Subject: again
+		C: Message-Id: <2@example.com>
+		C:
+		C: Hello again.
+		C: .
+		S: 240 article received OK
+		C: IHAVE <2@example.com>
+		S: 435 Article not wanted
+		C: GROUP misc.test
+		S: 211 2 1 2 misc.test
+	`)
+}
+
+func TestAuthenticatedSession(t *testing.T) {
+	s := newServer(t)
+	s.Authenticator = nntpserver.StaticAuthenticator{"user": "secret"}
+	s.Transcript(t, `
+		C: GROUP misc.test
+		S: 480 authentication required
+		C: AUTHINFO USER user
+		S: 381 Password required
+		C: AUTHINFO PASS secret
+		S: 281 Authentication accepted
+		C: GROUP misc.test
+		S: 211 1 1 1 misc.test
+	`)
+}
+
+func TestOverCurrentArticle(t *testing.T) {
+	s := newServer(t)
+	for _, id := range []string{"<2@example.com>", "<3@example.com>"} {
+		s.Backend.AddArticle("Message-Id: " + id + "\r\n" +
+			"Newsgroups: misc.test\r\n" +
+			"Subject: more\r\n" +
+			"\r\n" +
+			"Hi.\r\n")
+	}
+	s.Backend.AddGroup("empty.test", "Nothing", nntp.PostingPermitted)
+	s.Transcript(t, `
+		C: GROUP empty.test
+		S: 211 0 1 0 empty.test
+		C: OVER
+		S: 420 Current article number is invalid
+		C: GROUP misc.test
+		S: 211 3 1 3 misc.test
+		C: OVER
+		S: 224 here it comes
+		S: 1	hello	test@example.com		<1@example.com>		14	1
+		S: .
+		C: HEAD 2
+		S: 221 2 <2@example.com>
+		S: Message-Id: <2@example.com>
+		S: Newsgroups: misc.test
+		S: Subject: more
+		S: .
+		C: XOVER
+		S: 224 here it comes
+		S: 2	more			<2@example.com>		5	1
+		S: .
+	`)
+}
@@ -0,0 +1,272 @@
+package nntpserver
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var errTooManySessions = &NNTPError{Code: 400, Msg: "Too many connections, try again later"}
+var errTooManyHostSessions = &NNTPError{Code: 502, Msg: "Too many connections from your host"}
+var errQuotaExceeded = &NNTPError{Code: 502, Msg: "Daily download quota exceeded"}
+
+// Commands whose output counts towards Server.DailyQuota.
+var quotaCommands = map[string]bool{
+	"article": true,
+	"body":    true,
+	"head":    true,
+	"over":    true,
+	"xover":   true,
+	"xzver":   true,
+	"xhdr":    true,
+}
+
+// now is the clock used by rate limits and quotas.
+var now = time.Now
+
+// A Rate limits how quickly something may be used, as a token bucket
+// refilled at PerSecond and holding at most Burst.  The zero Rate is
+// unlimited.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+type bucket struct {
+	mu     sync.Mutex
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+// newBucket returns a full bucket for r, or nil if r is unlimited.
+func newBucket(r Rate) *bucket {
+	if r.PerSecond <= 0 {
+		return nil
+	}
+	return &bucket{rate: r, tokens: float64(r.Burst), last: now()}
+}
+
+// take removes n tokens, going into debt if there aren't enough, and
+// returns how long to wait for the debt to be paid off.
+func (b *bucket) take(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := now()
+	b.tokens += t.Sub(b.last).Seconds() * b.rate.PerSecond
+	if max := float64(b.rate.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = t
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate.PerSecond * float64(time.Second))
+}
+
+// userLimits are shared by all of a user's sessions.
+type userLimits struct {
+	commands, bytes *bucket
+	// The user's sessions, guarded by the limiter.
+	sessions int
+
+	mu   sync.Mutex
+	day  string
+	used int64
+}
+
+func today() string {
+	return now().UTC().Format("2006-01-02")
+}
+
+// downloaded adds n bytes to today's usage and returns the total.
+func (u *userLimits) downloaded(n int) int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if d := today(); d != u.day {
+		u.day, u.used = d, 0
+	}
+	u.used += int64(n)
+	return u.used
+}
+
+// stale reports whether the limits hold nothing worth keeping once
+// the user has no sessions: the rate buckets refill soon enough, and
+// the quota only matters for the rest of the day it was used.
+func (u *userLimits) stale() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.sessions == 0 && u.day != today()
+}
+
+// limiter is a Server's count of sessions and its users' limits.
+type limiter struct {
+	mu       sync.Mutex
+	sessions int
+	hosts    map[string]int
+	users    map[string]*userLimits
+}
+
+// hostKey is the address sessions are counted under for
+// MaxHostSessions.
+func (s *Server) hostKey(addr net.Addr) string {
+	host := postingHost(addr)
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return host
+	case ip.To4() != nil:
+		if s.HostPrefixIPv4 > 0 {
+			ip = ip.Mask(net.CIDRMask(s.HostPrefixIPv4, 32))
+		}
+	case s.HostPrefixIPv6 > 0:
+		ip = ip.Mask(net.CIDRMask(s.HostPrefixIPv6, 128))
+	}
+	return ip.String()
+}
+
+// admit counts a new session from host, unless that would take it
+// over MaxSessions or MaxHostSessions.
+func (s *Server) admit(host string) error {
+	l := &s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s.MaxSessions > 0 && l.sessions >= s.MaxSessions {
+		return errTooManySessions
+	}
+	if s.MaxHostSessions > 0 && l.hosts[host] >= s.MaxHostSessions {
+		return errTooManyHostSessions
+	}
+	if l.hosts == nil {
+		l.hosts = map[string]int{}
+	}
+	l.sessions++
+	l.hosts[host]++
+	return nil
+}
+
+// release uncounts a session admitted from host.
+func (s *Server) release(host string) {
+	l := &s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessions--
+	if l.hosts[host]--; l.hosts[host] <= 0 {
+		delete(l.hosts, host)
+	}
+}
+
+// userLimits returns the limits shared by user's sessions, counting
+// a new session until releaseUser.  Users whose limits have gone
+// stale are forgotten.
+func (s *Server) userLimits(user string) *userLimits {
+	l := &s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, u := range l.users {
+		if u.stale() {
+			delete(l.users, name)
+		}
+	}
+	u := l.users[user]
+	if u == nil {
+		if l.users == nil {
+			l.users = map[string]*userLimits{}
+		}
+		u = &userLimits{
+			commands: newBucket(s.CommandRate),
+			bytes:    newBucket(s.ByteRate),
+		}
+		l.users[user] = u
+	}
+	u.sessions++
+	return u
+}
+
+// releaseUser uncounts a session sharing user's limits.
+func (s *Server) releaseUser(user string) {
+	l := &s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if u := l.users[user]; u != nil {
+		if u.sessions--; u.stale() {
+			delete(l.users, user)
+		}
+	}
+}
+
+// commandBucket returns the bucket limiting the session's commands.
+func (s *Session) commandBucket() *bucket {
+	if s.server.RateByUser && s.limits != nil {
+		return s.limits.commands
+	}
+	return s.commands
+}
+
+// byteBucket returns the bucket limiting the session's output.
+func (s *Session) byteBucket() *bucket {
+	if s.server.RateByUser && s.limits != nil {
+		return s.limits.bytes
+	}
+	return s.bytes
+}
+
+// overQuota reports whether cmd is refused because the user has used
+// up their DailyQuota.
+func (s *Session) overQuota(cmd string) bool {
+	return s.server.DailyQuota > 0 && s.limits != nil && quotaCommands[cmd] &&
+		s.limits.downloaded(0) >= s.server.DailyQuota
+}
+
+// limitedConn throttles writes to the session's ByteRate.
+type limitedConn struct {
+	net.Conn
+	s *Session
+}
+
+func (c *limitedConn) Write(p []byte) (int, error) {
+	time.Sleep(c.s.byteBucket().take(len(p)))
+	return c.Conn.Write(p)
+}
+
+// quotaCounter counts what's sent in successful responses to
+// quotaCommands towards the user's DailyQuota.  Status lines aren't
+// counted, and neither are error responses such as 430.
+type quotaCounter struct {
+	w io.Writer
+	s *Session
+}
+
+func (q *quotaCounter) Write(p []byte) (int, error) {
+	n, err := q.w.Write(p)
+	if u := q.s.limits; u != nil && quotaCommands[q.s.command] {
+		u.downloaded(q.s.payload(p[:n]))
+	}
+	return n, err
+}
+
+// payload returns how many bytes of p, the next part of the response
+// to the current command, follow a successful status line.
+func (s *Session) payload(p []byte) int {
+	if !s.statusSent {
+		if s.status == 0 && len(p) > 0 {
+			s.status = p[0]
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			return 0
+		}
+		s.statusSent = true
+		p = p[i+1:]
+	}
+	if s.status != '2' {
+		return 0
+	}
+	return len(p)
+}
@@ -0,0 +1,190 @@
+package nntpserver
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp"
+)
+
+func TestSessionLimits(t *testing.T) {
+	s := NewServer(authBackend{})
+	s.MaxSessions = 2
+	s.MaxHostSessions = 1
+	s.HostPrefixIPv4 = 24
+
+	connect := func(addr string) (net.Conn, int) {
+		a, b := net.Pipe()
+		go s.Process(fakeAddrConn{b, addr})
+		code, _, _ := textproto.NewConn(a).ReadCodeLine(0)
+		return a, code
+	}
+	first, code := connect("192.0.2.1:119")
+	if code != 200 {
+		t.Fatalf("Expected first session to be greeted, got %d", code)
+	}
+	if c, code := connect("192.0.2.2:119"); code != 502 {
+		t.Errorf("Expected 502 for a second session from the /24, got %d", code)
+		c.Close()
+	}
+	second, code := connect("198.51.100.1:119")
+	if code != 200 {
+		t.Fatalf("Expected session from another network, got %d", code)
+	}
+	defer second.Close()
+	if c, code := connect("203.0.113.1:119"); code != 400 {
+		t.Errorf("Expected 400 beyond MaxSessions, got %d", code)
+		c.Close()
+	}
+
+	first.Close()
+	for i := 0; i < 100; i++ {
+		c, code := connect("192.0.2.3:119")
+		c.Close()
+		if code == 200 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Session wasn't released after closing")
+}
+
+func TestBucket(t *testing.T) {
+	defer func() { now = time.Now }()
+	clock := time.Unix(0, 0)
+	now = func() time.Time { return clock }
+
+	b := newBucket(Rate{PerSecond: 10, Burst: 5})
+	for _, e := range []struct {
+		advance time.Duration
+		n       int
+		wait    time.Duration
+	}{
+		{0, 5, 0},
+		{0, 1, 100 * time.Millisecond},
+		{100 * time.Millisecond, 1, 100 * time.Millisecond},
+		{time.Second, 5, 0},
+		{0, 20, 2 * time.Second},
+	} {
+		clock = clock.Add(e.advance)
+		if got := b.take(e.n); got != e.wait {
+			t.Errorf("After %v, take(%d) = %v, expected %v",
+				e.advance, e.n, got, e.wait)
+		}
+	}
+	if newBucket(Rate{}).take(1000) != 0 {
+		t.Errorf("Expected zero Rate to be unlimited")
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	s := NewServer(feedBackend{articles: map[string]*nntp.Article{
+		"<big@example.com>": {
+			Header: textproto.MIMEHeader{"Message-Id": {"<big@example.com>"}},
+			Body:   strings.NewReader(body),
+		},
+	}})
+	s.Authenticator = testAuth
+	s.DailyQuota = 150
+
+	transcript(t, s, "192.0.2.1:119", []string{
+		"AUTHINFO USER user", "381",
+		"AUTHINFO PASS secret", "281",
+		"HEAD <big@example.com>", "221",
+		"HEAD <big@example.com>", "221",
+		"BODY <big@example.com>", "222",
+		"HEAD <big@example.com>", "502",
+	})
+	// The quota is per user, not per session.
+	transcript(t, s, "192.0.2.2:119", []string{
+		"AUTHINFO USER user", "381",
+		"AUTHINFO PASS secret", "281",
+		"HEAD <big@example.com>", "502",
+	})
+}
+
+// overviewFeed serves its articles in misc.test.
+type overviewFeed struct {
+	feedBackend
+}
+
+func (overviewFeed) GetGroup(name string) (*nntp.Group, error) {
+	return &nntp.Group{Name: name, Low: 1, High: 1, Count: 1}, nil
+}
+
+func (b overviewFeed) GetArticles(*nntp.Group, int64, int64) ([]NumberedArticle, error) {
+	var rv []NumberedArticle
+	for _, a := range b.articles {
+		rv = append(rv, NumberedArticle{Num: int64(len(rv) + 1), Article: a})
+	}
+	return rv, nil
+}
+
+func TestDailyQuotaCounts(t *testing.T) {
+	s := NewServer(overviewFeed{feedBackend{articles: map[string]*nntp.Article{
+		"<big@example.com>": {
+			Header: textproto.MIMEHeader{"Message-Id": {"<big@example.com>"}},
+			Bytes:  200,
+		},
+	}}})
+	s.Authenticator = testAuth
+	// Each overview line is about 40 bytes.
+	s.DailyQuota = 60
+
+	steps := []string{
+		"AUTHINFO USER user", "381",
+		"AUTHINFO PASS secret", "281",
+		"GROUP misc.test", "211",
+	}
+	// Neither status lines nor error responses count.
+	for i := 0; i < 10; i++ {
+		steps = append(steps, "HEAD <missing@example.com>", "430")
+	}
+	steps = append(steps,
+		"XOVER 1-", "224",
+		"OVER 1-", "224",
+		"XOVER 1-", "502",
+	)
+	transcript(t, s, "192.0.2.1:119", steps)
+}
+
+func TestUserLimitsPruned(t *testing.T) {
+	defer func() { now = time.Now }()
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+
+	s := NewServer(authBackend{})
+	s.DailyQuota = 100
+	known := func(user string) bool {
+		_, ok := s.limits.users[user]
+		return ok
+	}
+
+	s.userLimits("idle")
+	s.releaseUser("idle")
+	if known("idle") {
+		t.Errorf("Expected a user with no sessions or usage to be forgotten")
+	}
+
+	s.userLimits("reader").downloaded(50)
+	s.userLimits("reader")
+	s.releaseUser("reader")
+	s.releaseUser("reader")
+	if !known("reader") {
+		t.Fatalf("Expected today's usage to be kept")
+	}
+	if got := s.userLimits("reader").downloaded(0); got != 50 {
+		t.Errorf("Expected usage of 50 in a new session, got %d", got)
+	}
+	s.releaseUser("reader")
+
+	clock = clock.Add(24 * time.Hour)
+	s.userLimits("other")
+	if known("reader") {
+		t.Errorf("Expected yesterday's usage to be forgotten")
+	}
+}
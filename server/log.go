@@ -0,0 +1,53 @@
+package nntpserver
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+var sessionIDs int64
+
+// logger returns the server's logger, or the default one.
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// log returns a logger with the session's current attributes.
+func (s *Session) log() *slog.Logger {
+	l := s.logBase
+	if s.user != "" {
+		l = l.With("user", s.user)
+	}
+	if s.group != nil {
+		l = l.With("group", s.group.Name)
+	}
+	return l
+}
+
+// startLog sets up the session's logging attributes.
+func (s *Session) startLog() {
+	s.id = atomic.AddInt64(&sessionIDs, 1)
+	s.logBase = s.server.logger().With("session", s.id,
+		"remote", postingHost(s.remoteAddr))
+}
+
+// redact hides credentials in a command line before it's logged.
+func redact(line string) string {
+	parts := strings.Fields(line)
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "authinfo") {
+		return line
+	}
+	switch strings.ToLower(parts[1]) {
+	case "pass":
+		return strings.Join(parts[:2], " ") + " [redacted]"
+	case "sasl":
+		if len(parts) > 3 {
+			return strings.Join(parts[:3], " ") + " [redacted]"
+		}
+	}
+	return line
+}
@@ -0,0 +1,327 @@
+package nntpserver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics receives events from a Server, to be exported to whatever
+// monitoring system is in use.  Methods may be called concurrently.
+type Metrics interface {
+	// SessionStarted and SessionEnded bracket each session.
+	SessionStarted()
+	SessionEnded()
+	// Command is called after each command with its lower case
+	// name, or "unknown", and the response code sent.
+	Command(name string, code int, d time.Duration)
+	// BytesRead and BytesWritten count traffic on the connection.
+	BytesRead(n int)
+	BytesWritten(n int)
+	// AuthFailed is called for each rejected authentication.
+	AuthFailed()
+	// Article is called for each article offered by POST, IHAVE or
+	// TAKETHIS.
+	Article(accepted bool)
+	// Backend is called after each call to a Backend method.
+	Backend(method string, d time.Duration, err error)
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency
+// histograms kept by a MetricsRegistry.
+var DefaultBuckets = []float64{.001, .005, .01, .05, .1, .5, 1, 5}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// A MetricsRegistry is a Metrics keeping counters and histograms in
+// memory.  It serves them over HTTP in the Prometheus text format.
+type MetricsRegistry struct {
+	mu              sync.Mutex
+	sessionsActive  int64
+	sessionsTotal   uint64
+	commands        map[string]uint64
+	commandLatency  map[string]*histogram
+	bytesRead       uint64
+	bytesWritten    uint64
+	authFailures    uint64
+	articles        map[string]uint64
+	backendLatency  map[string]*histogram
+	backendFailures map[string]uint64
+}
+
+// NewMetricsRegistry makes an empty MetricsRegistry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		commands:        map[string]uint64{},
+		commandLatency:  map[string]*histogram{},
+		articles:        map[string]uint64{},
+		backendLatency:  map[string]*histogram{},
+		backendFailures: map[string]uint64{},
+	}
+}
+
+// SessionStarted implements Metrics.
+func (m *MetricsRegistry) SessionStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionsActive++
+	m.sessionsTotal++
+}
+
+// SessionEnded implements Metrics.
+func (m *MetricsRegistry) SessionEnded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionsActive--
+}
+
+// Command implements Metrics.
+func (m *MetricsRegistry) Command(name string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands[fmt.Sprintf(`command=%q,code="%d"`, name, code)]++
+	observe(m.commandLatency, fmt.Sprintf("command=%q", name), d)
+}
+
+// BytesRead implements Metrics.
+func (m *MetricsRegistry) BytesRead(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesRead += uint64(n)
+}
+
+// BytesWritten implements Metrics.
+func (m *MetricsRegistry) BytesWritten(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesWritten += uint64(n)
+}
+
+// AuthFailed implements Metrics.
+func (m *MetricsRegistry) AuthFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authFailures++
+}
+
+// Article implements Metrics.
+func (m *MetricsRegistry) Article(accepted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if accepted {
+		m.articles[`result="accepted"`]++
+	} else {
+		m.articles[`result="rejected"`]++
+	}
+}
+
+// Backend implements Metrics.
+func (m *MetricsRegistry) Backend(method string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	label := fmt.Sprintf("method=%q", method)
+	observe(m.backendLatency, label, d)
+	if err != nil {
+		m.backendFailures[label]++
+	}
+}
+
+func observe(hs map[string]*histogram, label string, d time.Duration) {
+	h := hs[label]
+	if h == nil {
+		h = &histogram{}
+		hs[label] = h
+	}
+	h.observe(DefaultBuckets, d.Seconds())
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	header := func(name, typ, help string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	single := func(name, typ, help string, v interface{}) {
+		header(name, typ, help)
+		fmt.Fprintf(cw, "%s %v\n", name, v)
+	}
+	labelled := func(name, help string, vals map[string]uint64) {
+		header(name, "counter", help)
+		for _, k := range sortedKeys(vals) {
+			fmt.Fprintf(cw, "%s{%s} %d\n", name, k, vals[k])
+		}
+	}
+	histograms := func(name, help string, hs map[string]*histogram) {
+		header(name, "histogram", help)
+		keys := make([]string, 0, len(hs))
+		for k := range hs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			h := hs[k]
+			for i, b := range DefaultBuckets {
+				fmt.Fprintf(cw, "%s_bucket{%s,le=\"%g\"} %d\n", name, k, b, h.counts[i])
+			}
+			fmt.Fprintf(cw, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k, h.count)
+			fmt.Fprintf(cw, "%s_sum{%s} %g\n", name, k, h.sum)
+			fmt.Fprintf(cw, "%s_count{%s} %d\n", name, k, h.count)
+		}
+	}
+
+	single("nntp_sessions_active", "gauge", "Sessions in progress.", m.sessionsActive)
+	single("nntp_sessions_total", "counter", "Sessions started.", m.sessionsTotal)
+	labelled("nntp_commands_total", "Commands handled, by response code.", m.commands)
+	histograms("nntp_command_duration_seconds", "Time to handle commands.", m.commandLatency)
+	single("nntp_read_bytes_total", "counter", "Bytes read from clients.", m.bytesRead)
+	single("nntp_written_bytes_total", "counter", "Bytes written to clients.", m.bytesWritten)
+	single("nntp_auth_failures_total", "counter", "Rejected authentications.", m.authFailures)
+	labelled("nntp_articles_total", "Articles offered, by result.", m.articles)
+	histograms("nntp_backend_duration_seconds", "Time spent in backend calls.", m.backendLatency)
+	labelled("nntp_backend_errors_total", "Backend calls returning errors.", m.backendFailures)
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// meteredConn counts the bytes passing through a connection.
+type meteredConn struct {
+	net.Conn
+	m Metrics
+}
+
+func (c *meteredConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.m.BytesRead(n)
+	}
+	return n, err
+}
+
+func (c *meteredConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.m.BytesWritten(n)
+	}
+	return n, err
+}
+
+// codeSniffer notes the response code at the start of the first
+// write after each command.
+type codeSniffer struct {
+	w io.Writer
+	s *Session
+}
+
+func (c *codeSniffer) Write(p []byte) (int, error) {
+	if c.s.responseCode == 0 && len(p) >= 3 {
+		var code int
+		if _, err := fmt.Sscanf(string(p[:3]), "%d", &code); err == nil {
+			c.s.responseCode = code
+		}
+	}
+	return c.w.Write(p)
+}
+
+// setConn makes nc the session's connection.
+func (s *Session) setConn(nc net.Conn) {
+	s.netconn = nc
+	s.conn = textproto.NewConn(nc)
+	if s.server.Metrics == nil && s.server.DailyQuota <= 0 {
+		return
+	}
+	var w io.Writer = nc
+	if s.server.Metrics != nil {
+		w = &codeSniffer{w: w, s: s}
+	}
+	if s.server.DailyQuota > 0 {
+		w = &quotaCounter{w: w, s: s}
+	}
+	s.conn.W = bufio.NewWriter(w)
+}
+
+// timed reports a backend call that began at start.
+func (s *Session) timed(method string, start time.Time, err error) {
+	if m := s.server.Metrics; m != nil {
+		m.Backend(method, time.Since(start), err)
+	}
+}
+
+// articleOffered reports the fate of an offered article.
+func (s *Session) articleOffered(err error) {
+	if m := s.server.Metrics; m != nil {
+		m.Article(err == nil)
+	}
+}
+
+// authFailed reports a rejected authentication.
+func (s *Session) authFailed() {
+	s.log().Info("authentication failed")
+	if m := s.server.Metrics; m != nil {
+		m.AuthFailed()
+	}
+}
+
+// commandLabel is the name commands are counted under, limited to
+// those with handlers to keep the number of labels down.
+func (s *Session) commandLabel(cmd string) string {
+	cmd = strings.ToLower(cmd)
+	if _, ok := s.server.Handlers[cmd]; !ok || cmd == "" {
+		return "unknown"
+	}
+	return cmd
+}
@@ -0,0 +1,68 @@
+package nntpserver
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-nntp"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	m := NewMetricsRegistry()
+	s := NewServer(groupBackend{})
+	s.Authenticator = testAuth
+	s.Metrics = m
+
+	transcript(t, s, "192.0.2.1:119", []string{
+		"GROUP misc.test", "480",
+		"AUTHINFO USER user", "381",
+		"AUTHINFO PASS wrong", "481",
+		"AUTHINFO USER user", "381",
+		"AUTHINFO PASS secret", "281",
+		"GROUP misc.test", "211",
+		"XNONSENSE", "500",
+	})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		"nntp_sessions_total 1\n",
+		`nntp_commands_total{command="group",code="480"} 1`,
+		`nntp_commands_total{command="group",code="211"} 1`,
+		`nntp_commands_total{command="authinfo",code="381"} 2`,
+		`nntp_commands_total{command="unknown",code="500"} 1`,
+		`nntp_command_duration_seconds_count{command="group"} 2`,
+		"nntp_auth_failures_total 1\n",
+		`nntp_backend_duration_seconds_count{method="GetGroup"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "nntp_read_bytes_total 0\n") {
+		t.Errorf("Expected bytes read to be counted:\n%s", out)
+	}
+}
+
+func TestMetricsArticles(t *testing.T) {
+	m := NewMetricsRegistry()
+	s := NewServer(feedBackend{articles: map[string]*nntp.Article{}})
+	s.Metrics = m
+	s.Validator = &Validator{}
+
+	transcript(t, s, "192.0.2.1:119", []string{
+		"IHAVE <a@example.com>", "335",
+		"Message-Id: <a@example.com>\r\n\r\nincomplete\r\n.", "437",
+	})
+	if !strings.Contains(metricsText(m), `nntp_articles_total{result="rejected"} 1`) {
+		t.Errorf("Expected a rejected article:\n%s", metricsText(m))
+	}
+}
+
+func metricsText(m *MetricsRegistry) string {
+	var b strings.Builder
+	m.WriteTo(&b)
+	return b.String()
+}
@@ -0,0 +1,222 @@
+package nntpserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyHeaderTimeout bounds how long a trusted proxy may take to send
+// its PROXY protocol header.
+const proxyHeaderTimeout = 10 * time.Second
+
+var errProxyHeader = errors.New("malformed PROXY protocol header")
+
+var errNoTLSConfig = errors.New("nntpserver: ServeTLS needs a TLSConfig")
+
+// The signature starting a version 2 PROXY protocol header.
+var proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Serve accepts connections on l and processes each in its own
+// goroutine.  Connections from ProxyProtocol sources have their PROXY
+// header read first.  Temporary Accept errors, such as running out of
+// file descriptors, are retried with a backoff, as net/http does; Serve
+// returns on any other.
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, false)
+}
+
+// ServeTLS is Serve for implicit TLS (NNTPS, usually on port 563),
+// wrapping each connection with TLSConfig.  l should not itself be a
+// TLS listener, since a PROXY header comes before the TLS handshake.
+func (s *Server) ServeTLS(l net.Listener) error {
+	if s.TLSConfig == nil {
+		return errNoTLSConfig
+	}
+	return s.serve(l, true)
+}
+
+func (s *Server) serve(l net.Listener, useTLS bool) error {
+	var delay time.Duration
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				s.logger().Warn("accept failed, retrying",
+					"err", err, "delay", delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		go s.serveConn(c, useTLS)
+	}
+}
+
+func (s *Server) serveConn(c net.Conn, useTLS bool) {
+	if containsAddr(s.ProxyProtocol, c.RemoteAddr()) {
+		pc, err := readProxyHeader(c)
+		if err != nil {
+			s.logger().Warn("dropping conn from proxy",
+				"proxy", postingHost(c.RemoteAddr()), "err", err)
+			c.Close()
+			return
+		}
+		c = pc
+	}
+	if useTLS {
+		c = tls.Server(c, s.TLSConfig)
+	}
+	s.Process(c)
+}
+
+// containsAddr reports whether addr is in any of nets.
+func containsAddr(nets []*net.IPNet, addr net.Addr) bool {
+	ip := net.ParseIP(postingHost(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn is a connection relayed by a proxy, reporting the client's
+// address as its remote address.
+type proxyConn struct {
+	net.Conn
+	r    *bufio.Reader
+	addr net.Addr
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// readProxyHeader reads a version 1 or 2 PROXY protocol header from c.
+// Headers for local or unknown connections leave the proxy's address
+// in place.
+func readProxyHeader(c net.Conn) (net.Conn, error) {
+	c.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer c.SetReadDeadline(time.Time{})
+
+	pc := &proxyConn{Conn: c, r: bufio.NewReader(c), addr: c.RemoteAddr()}
+	sig, err := pc.r.Peek(len(proxyV2Sig))
+	if err != nil {
+		return nil, err
+	}
+	var addr net.Addr
+	if bytes.Equal(sig, proxyV2Sig) {
+		addr, err = readProxyV2(pc.r)
+	} else {
+		addr, err = readProxyV1(pc.r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if addr != nil {
+		pc.addr = addr
+	}
+	return pc, nil
+}
+
+// readProxyV1 reads a header such as
+// "PROXY TCP4 192.0.2.1 198.51.100.1 56324 119\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errProxyHeader
+	}
+	f := strings.Fields(string(line))
+	switch {
+	case len(f) < 2 || f[0] != "PROXY":
+		return nil, errProxyHeader
+	case f[1] == "UNKNOWN":
+		return nil, nil
+	case len(f) != 6 || (f[1] != "TCP4" && f[1] != "TCP6"):
+		return nil, errProxyHeader
+	}
+	// Both addresses must be of the line's family: IPv6 addresses
+	// always have a colon, and IPv4 ones never do.
+	v6 := f[1] == "TCP6"
+	for _, a := range f[2:4] {
+		if net.ParseIP(a) == nil || strings.Contains(a, ":") != v6 {
+			return nil, errProxyHeader
+		}
+	}
+	for _, p := range f[4:6] {
+		if _, err := strconv.ParseUint(p, 10, 16); err != nil {
+			return nil, errProxyHeader
+		}
+	}
+	port, _ := strconv.ParseUint(f[4], 10, 16)
+	return &net.TCPAddr{IP: net.ParseIP(f[2]), Port: int(port)}, nil
+}
+
+// readProxyV2 reads a binary header.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, errProxyHeader
+	}
+	switch hdr[12] & 0xf {
+	case 0: // LOCAL, such as a health check.
+		return nil, nil
+	case 1: // PROXY
+	default:
+		return nil, errProxyHeader
+	}
+	var iplen int
+	switch hdr[13] >> 4 {
+	case 1:
+		iplen = net.IPv4len
+	case 2:
+		iplen = net.IPv6len
+	default:
+		return nil, nil
+	}
+	if len(body) < 2*iplen+4 {
+		return nil, errProxyHeader
+	}
+	return &net.TCPAddr{
+		IP:   net.IP(body[:iplen]),
+		Port: int(binary.BigEndian.Uint16(body[2*iplen:])),
+	}, nil
+}
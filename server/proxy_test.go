@@ -0,0 +1,164 @@
+package nntpserver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func proxyV2(cmd byte, fam byte, addr []byte) string {
+	h := append([]byte{}, proxyV2Sig...)
+	h = append(h, 0x20|cmd, fam, 0, 0)
+	binary.BigEndian.PutUint16(h[14:], uint16(len(addr)))
+	return string(append(h, addr...))
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	v4 := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0, 119}
+	tests := []struct {
+		header string
+		exp    string
+	}{
+		{"PROXY TCP4 192.0.2.1 198.51.100.1 56324 119\r\n", "192.0.2.1:56324"},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 119\r\n", "[2001:db8::1]:56324"},
+		{"PROXY UNKNOWN\r\n", "pipe"},
+		{proxyV2(1, 0x11, v4), "192.0.2.1:56324"},
+		{proxyV2(0, 0x00, nil), "pipe"},
+		{"PROXY TCP4 192.0.2.1\r\n", ""},
+		{"PROXY TCP4 2001:db8::1 198.51.100.1 56324 119\r\n", ""},
+		{"PROXY TCP4 192.0.2.1 2001:db8::2 56324 119\r\n", ""},
+		{"PROXY TCP6 192.0.2.1 2001:db8::2 56324 119\r\n", ""},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 99999\r\n", ""},
+		{"GROUP misc.test\r\n", ""},
+		{proxyV2(1, 0x11, v4[:4]), ""},
+	}
+	for _, test := range tests {
+		a, b := net.Pipe()
+		go func() {
+			a.Write([]byte(test.header + "QUIT\r\n"))
+			a.Close()
+		}()
+		c, err := readProxyHeader(b)
+		switch {
+		case test.exp == "":
+			if err == nil {
+				t.Errorf("Expected error for %q", test.header)
+			}
+		case err != nil:
+			t.Errorf("Error reading %q: %v", test.header, err)
+		case c.RemoteAddr().String() != test.exp:
+			t.Errorf("%q: expected address %v, got %v",
+				test.header, test.exp, c.RemoteAddr())
+		default:
+			if l, _ := bufio.NewReader(c).ReadString('\n'); l != "QUIT\r\n" {
+				t.Errorf("%q: expected QUIT after header, got %q", test.header, l)
+			}
+		}
+		b.Close()
+	}
+}
+
+func TestServeProxied(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	defer l.Close()
+	s := NewServer(authBackend{})
+	s.ProxyProtocol, _ = ParseNetworks("127.0.0.1")
+	s.Handlers["xaddr"] = func(args []string, s *Session, c *textproto.Conn) error {
+		return c.PrintfLine("280 %s", s.RemoteAddr())
+	}
+	go s.Serve(l)
+
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+	defer nc.Close()
+	nc.Write([]byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 119\r\n"))
+	c := textproto.NewConn(nc)
+	if _, _, err := c.ReadCodeLine(200); err != nil {
+		t.Fatalf("Error reading greeting: %v", err)
+	}
+	c.PrintfLine("XADDR")
+	if _, msg, err := c.ReadCodeLine(280); err != nil || msg != "192.0.2.1:56324" {
+		t.Errorf("Expected client address, got %q (%v)", msg, err)
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// flakyListener fails with errs in turn, then with net.ErrClosed.
+type flakyListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if len(l.errs) == 0 {
+		return nil, net.ErrClosed
+	}
+	err := l.errs[0]
+	l.errs = l.errs[1:]
+	return nil, err
+}
+
+func TestServeRetries(t *testing.T) {
+	s := NewServer(authBackend{})
+	l := &flakyListener{errs: []error{temporaryError{}, temporaryError{}, temporaryError{}}}
+	start := time.Now()
+	if err := s.Serve(l); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected Serve to return the permanent error, got %v", err)
+	}
+	if len(l.errs) != 0 {
+		t.Errorf("Expected temporary errors to be retried, %d left", len(l.errs))
+	}
+	// 5ms, 10ms and 20ms.
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Expected backoff between retries, took %v", elapsed)
+	}
+}
+
+func TestServeTLSProxied(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	defer l.Close()
+	s := NewServer(authBackend{})
+	if err := s.ServeTLS(l); err != errNoTLSConfig {
+		t.Errorf("Expected ServeTLS to need a TLSConfig, got %v", err)
+	}
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{testCert(t)}}
+	s.ProxyProtocol, _ = ParseNetworks("127.0.0.1")
+	s.Handlers["xaddr"] = func(args []string, s *Session, c *textproto.Conn) error {
+		return c.PrintfLine("280 %s %v", s.RemoteAddr(), s.TLSState() != nil)
+	}
+	go s.ServeTLS(l)
+
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+	defer nc.Close()
+	// The header comes first, in the clear.
+	nc.Write([]byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 563\r\n"))
+	c := textproto.NewConn(tls.Client(nc, &tls.Config{InsecureSkipVerify: true}))
+	if _, _, err := c.ReadCodeLine(200); err != nil {
+		t.Fatalf("Error reading greeting over TLS: %v", err)
+	}
+	c.PrintfLine("XADDR")
+	if _, msg, err := c.ReadCodeLine(280); err != nil || msg != "192.0.2.1:56324 true" {
+		t.Errorf("Expected client address over TLS, got %q (%v)", msg, err)
+	}
+}
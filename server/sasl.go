@@ -0,0 +1,180 @@
+package nntpserver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/textproto"
+	"strings"
+)
+
+// A SASLMechanism is a SASL mechanism offered by AUTHINFO SASL.
+type SASLMechanism interface {
+	// Name is the mechanism's registered name, such as "PLAIN".
+	Name() string
+	// Start begins an exchange for session s, whose TLSState is
+	// there for mechanisms such as EXTERNAL.  a is the server's
+	// Authenticator, which may be nil.
+	Start(a Authenticator, s *Session) SASLExchange
+}
+
+// A SASLExchange is one authentication attempt with a SASLMechanism.
+type SASLExchange interface {
+	// Next is given each client response and returns the next
+	// challenge.  The first call gets the initial response, which is
+	// nil if the client didn't send one.  done reports success, in
+	// which case any challenge is sent as additional success data.
+	// An error fails the exchange.
+	Next(response []byte) (challenge []byte, done bool, err error)
+	// User returns the authenticated identity after success.
+	User() string
+}
+
+// PlainMechanism is SASL PLAIN (RFC 4616), checking the credentials
+// with the server's Authenticator.  It's only offered when the server
+// has one.
+type PlainMechanism struct{}
+
+// Name returns "PLAIN".
+func (PlainMechanism) Name() string {
+	return "PLAIN"
+}
+
+// Start begins a PLAIN exchange.
+func (PlainMechanism) Start(a Authenticator, s *Session) SASLExchange {
+	return &plainExchange{auth: a}
+}
+
+type plainExchange struct {
+	auth Authenticator
+	user string
+}
+
+func (p *plainExchange) Next(response []byte) ([]byte, bool, error) {
+	if response == nil {
+		// Ask for the credentials with an empty challenge.
+		return nil, false, nil
+	}
+	parts := bytes.Split(response, []byte{0})
+	if len(parts) != 3 {
+		return nil, false, ErrSASLProtocol
+	}
+	authz, user, pass := string(parts[0]), string(parts[1]), string(parts[2])
+	if p.auth == nil || (authz != "" && authz != user) {
+		return nil, false, ErrAuthRejected
+	}
+	if err := p.auth.Authenticate(user, pass); err != nil {
+		return nil, false, err
+	}
+	p.user = user
+	return nil, true, nil
+}
+
+func (p *plainExchange) User() string {
+	return p.user
+}
+
+var errMechanism = &NNTPError{Code: 503, Msg: "Mechanism not recognized"}
+var errBase64 = &NNTPError{Code: 504, Msg: "Base64 encoding error"}
+var errSASLCancelled = &NNTPError{Code: 481, Msg: "Authentication cancelled"}
+
+func (s *Server) saslMechanism(name string) SASLMechanism {
+	for _, m := range s.SASLMechanisms {
+		if strings.EqualFold(m.Name(), name) {
+			return m
+		}
+	}
+	return nil
+}
+
+// saslNames lists the usable mechanism names for CAPABILITIES.
+func (s *Server) saslNames() string {
+	var names []string
+	for _, m := range s.SASLMechanisms {
+		if _, plain := m.(PlainMechanism); plain && s.Authenticator == nil {
+			continue
+		}
+		names = append(names, m.Name())
+	}
+	return strings.Join(names, " ")
+}
+
+func encodeSASL(b []byte) string {
+	if len(b) == 0 {
+		return "="
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func decodeSASL(s string) ([]byte, error) {
+	if s == "=" {
+		return []byte{}, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errBase64
+	}
+	return b, nil
+}
+
+/*
+   Syntax
+     AUTHINFO SASL mechanism [initial-response]
+
+   Responses
+     281    Authentication accepted
+     283    challenge  Authentication accepted (with success data)
+     383    challenge  Continue with SASL exchange
+     481    Authentication failed/rejected
+     482    SASL protocol error
+     502    Command unavailable
+
+   See https://datatracker.ietf.org/doc/html/rfc4643#section-2.4
+*/
+
+func handleAuthInfoSASL(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) < 2 || len(args) > 3 {
+		return ErrSyntax
+	}
+	mech := s.server.saslMechanism(args[1])
+	if mech == nil {
+		return errMechanism
+	}
+	var response []byte
+	if len(args) == 3 {
+		var err error
+		if response, err = decodeSASL(args[2]); err != nil {
+			return err
+		}
+	}
+
+	ex := mech.Start(s.server.Authenticator, s)
+	for {
+		challenge, done, err := ex.Next(response)
+		if err != nil {
+			s.authFailed()
+			return err
+		}
+		if done {
+			if err := s.login(ex.User()); err != nil {
+				return err
+			}
+			if len(challenge) > 0 {
+				return c.PrintfLine("283 %s", encodeSASL(challenge))
+			}
+			return c.PrintfLine("281 Authentication accepted")
+		}
+		if err := c.PrintfLine("383 %s", encodeSASL(challenge)); err != nil {
+			return err
+		}
+		line, err := c.ReadLine()
+		if err != nil {
+			return err
+		}
+		if line == "*" {
+			return errSASLCancelled
+		}
+		if response, err = decodeSASL(line); err != nil {
+			return err
+		}
+	}
+}
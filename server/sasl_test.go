@@ -0,0 +1,106 @@
+package nntpserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/textproto"
+	"testing"
+)
+
+type authBackend struct {
+	Backend
+}
+
+func (authBackend) AllowPost() bool {
+	return false
+}
+
+var testAuth = StaticAuthenticator{"user": "secret"}
+
+func TestPlainMechanism(t *testing.T) {
+	tests := []struct {
+		response string
+		err      error
+	}{
+		{"\x00user\x00secret", nil},
+		{"user\x00user\x00secret", nil},
+		{"\x00user\x00wrong", ErrAuthRejected},
+		{"other\x00user\x00secret", ErrAuthRejected},
+		{"user\x00secret", ErrSASLProtocol},
+	}
+	for _, test := range tests {
+		ex := PlainMechanism{}.Start(testAuth, nil)
+		challenge, done, err := ex.Next(nil)
+		if err != nil || done || len(challenge) != 0 {
+			t.Fatalf("Expected an empty challenge, got %q, %v, %v",
+				challenge, done, err)
+		}
+		_, done, err = ex.Next([]byte(test.response))
+		if !errors.Is(err, test.err) || done != (test.err == nil) {
+			t.Errorf("%q: got done=%v, err=%v; expected %v",
+				test.response, done, err, test.err)
+		}
+	}
+}
+
+// externalMechanism is SASL EXTERNAL, taking the identity from the
+// TLS client certificate.
+type externalMechanism struct{}
+
+func (externalMechanism) Name() string {
+	return "EXTERNAL"
+}
+
+func (externalMechanism) Start(a Authenticator, s *Session) SASLExchange {
+	return &externalExchange{s: s}
+}
+
+type externalExchange struct {
+	s    *Session
+	user string
+}
+
+func (e *externalExchange) Next(response []byte) ([]byte, bool, error) {
+	st := e.s.TLSState()
+	if st == nil || len(st.PeerCertificates) == 0 {
+		return nil, false, ErrAuthRejected
+	}
+	e.user = st.PeerCertificates[0].DNSNames[0]
+	return nil, true, nil
+}
+
+func (e *externalExchange) User() string {
+	return e.user
+}
+
+func TestSASLMechanismTLSState(t *testing.T) {
+	s := NewServer(authBackend{})
+	s.SASLMechanisms = append(s.SASLMechanisms, externalMechanism{})
+	// Wraps the session's connection.
+	s.ByteRate = Rate{PerSecond: 1e9, Burst: 1e9}
+	s.Handlers["xwho"] = func(args []string, s *Session, c *textproto.Conn) error {
+		return c.PrintfLine("280 %s", s.User())
+	}
+	cert := testCert(t)
+
+	a, b := net.Pipe()
+	defer a.Close()
+	go s.Process(tls.Server(b, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}))
+	c := textproto.NewConn(tls.Client(a, &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{cert},
+	}))
+	c.ReadCodeLine(200)
+	c.PrintfLine("AUTHINFO SASL EXTERNAL =")
+	if _, msg, err := c.ReadCodeLine(281); err != nil {
+		t.Fatalf("Expected EXTERNAL to authenticate over implicit TLS, got %q (%v)", msg, err)
+	}
+	c.PrintfLine("XWHO")
+	if _, msg, err := c.ReadCodeLine(280); err != nil || msg != "localhost" {
+		t.Errorf("Expected the certificate's identity, got %q (%v)", msg, err)
+	}
+}
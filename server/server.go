@@ -2,71 +2,95 @@
 package nntpserver
 
 import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"net"
 	"net/textproto"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-nntp"
 )
 
 // An NNTPError is a coded NNTP error message.
-type NNTPError struct {
-	Code int
-	Msg  string
-}
+type NNTPError = nntp.Error
+
+// The errors below are the shared values from the nntp package,
+// repeated here for convenience.
 
 // ErrNoSuchGroup is returned for a request for a group that can't be found.
-var ErrNoSuchGroup = &NNTPError{411, "No such newsgroup"}
+var ErrNoSuchGroup = nntp.ErrNoSuchGroup
 
-// ErrNoSuchGroup is returned for a request that requires a current
+// ErrNoGroupSelected is returned for a request that requires a current
 // group when none has been selected.
-var ErrNoGroupSelected = &NNTPError{412, "No newsgroup selected"}
+var ErrNoGroupSelected = nntp.ErrNoGroupSelected
 
 // ErrInvalidMessageID is returned when a message is requested that can't be found.
-var ErrInvalidMessageID = &NNTPError{430, "No article with that message-id"}
+var ErrInvalidMessageID = nntp.ErrInvalidMessageID
 
 // ErrInvalidArticleNumber is returned when an article is requested that can't be found.
-var ErrInvalidArticleNumber = &NNTPError{423, "No article with that number"}
+var ErrInvalidArticleNumber = nntp.ErrInvalidArticleNumber
 
 // ErrNoCurrentArticle is returned when a command is executed that
 // requires a current article when one has not been selected.
-var ErrNoCurrentArticle = &NNTPError{420, "Current article number is invalid"}
+var ErrNoCurrentArticle = nntp.ErrNoCurrentArticle
 
 // ErrUnknownCommand is returned for unknown comands.
-var ErrUnknownCommand = &NNTPError{500, "Unknown command"}
+var ErrUnknownCommand = nntp.ErrUnknownCommand
 
 // ErrSyntax is returned when a command can't be parsed.
-var ErrSyntax = &NNTPError{501, "not supported, or syntax error"}
+var ErrSyntax = nntp.ErrSyntax
 
 // ErrPostingNotPermitted is returned as the response to an attempt to
 // post an article where posting is not permitted.
-var ErrPostingNotPermitted = &NNTPError{440, "Posting not permitted"}
+var ErrPostingNotPermitted = nntp.ErrPostingNotPermitted
 
 // ErrPostingFailed is returned when an attempt to post an article fails.
-var ErrPostingFailed = &NNTPError{441, "posting failed"}
+var ErrPostingFailed = nntp.ErrPostingFailed
 
 // ErrNotWanted is returned when an attempt to post an article is
 // rejected due the server not wanting the article.
-var ErrNotWanted = &NNTPError{435, "Article not wanted"}
+var ErrNotWanted = nntp.ErrNotWanted
 
 // ErrAuthRequired is returned to indicate authentication is required
 // to proceed.
-var ErrAuthRequired = &NNTPError{450, "authorization required"}
+var ErrAuthRequired = nntp.ErrAuthRequired
 
 // ErrAuthRejected is returned for invalid authentication.
-var ErrAuthRejected = &NNTPError{452, "authorization rejected"}
+var ErrAuthRejected = nntp.ErrAuthRejected
 
 // ErrNotAuthenticated is returned when a command is issued that requires
 // authentication, but authentication was not provided.
-var ErrNotAuthenticated = &NNTPError{480, "authentication required"}
+var ErrNotAuthenticated = nntp.ErrNotAuthenticated
+
+// ErrAuthOutOfSequence is returned for AUTHINFO PASS without a
+// preceding AUTHINFO USER.
+var ErrAuthOutOfSequence = nntp.ErrAuthOutOfSequence
+
+// ErrAccessDenied is returned when access to a group is refused.
+var ErrAccessDenied = nntp.ErrAccessDenied
+
+// ErrSASLProtocol is returned when a SASL exchange is malformed.
+var ErrSASLProtocol = nntp.ErrSASLProtocol
+
+// ErrCommandUnavailable is returned for a command that's recognized
+// but not available in the session's current state.
+var ErrCommandUnavailable = nntp.ErrCommandUnavailable
 
 // Handler is a low-level protocol handler
-type Handler func(args []string, s *session, c *textproto.Conn) error
+type Handler func(args []string, s *Session, c *textproto.Conn) error
+
+// A Middleware wraps a Handler, to run code around every command.
+type Middleware func(next Handler) Handler
 
 // A NumberedArticle provides local sequence nubers to articles When
 // listing articles in a group.
@@ -79,40 +103,167 @@ type NumberedArticle struct {
 type Backend interface {
 	ListGroups(max int) ([]*nntp.Group, error)
 	GetGroup(name string) (*nntp.Group, error)
+	// GetArticle finds an article by message-id, including the angle
+	// brackets, or by its number in group.  group may be nil for
+	// message-id lookups.
 	GetArticle(group *nntp.Group, id string) (*nntp.Article, error)
 	GetArticles(group *nntp.Group, from, to int64) ([]NumberedArticle, error)
-	Authorized() bool
-	// Authenticate and optionally swap out the backend for this session.
-	// You may return nil to continue using the same backend.
-	Authenticate(user, pass string) (Backend, error)
 	AllowPost() bool
 	Post(article *nntp.Article) error
 }
 
-type session struct {
-	server  *Server
-	backend Backend
-	group   *nntp.Group
+// A Session is the state of one client connection.
+type Session struct {
+	// Values holds whatever handlers and middleware want to keep
+	// for the session.  It's emptied by STARTTLS, along with the
+	// rest of the session's state.
+	Values map[string]interface{}
+
+	server     *Server
+	backend    Backend
+	group      *nntp.Group
+	article    int64
+	remoteAddr net.Addr
+	// The session's current connection, which changes when
+	// compression is turned on.
+	netconn net.Conn
+	conn    *textproto.Conn
+	// The TLS connection, if the session uses TLS.
+	tlsConn *tls.Conn
+	// Set in reader mode, as opposed to transit.
+	reader bool
+	// Set for sessions from FeedPeers.
+	feedPeer bool
+	// Set once COMPRESS DEFLATE is active.
+	compressed    bool
+	authenticated bool
+	// The authenticated user.
+	user string
+	// For logging.
+	id      int64
+	logBase *slog.Logger
+	// The command being handled.
+	command string
+	// Set while a TAKETHIS article is waiting to be read.
+	articlePending bool
+	// The user name from AUTHINFO USER, awaiting AUTHINFO PASS.
+	authUser    string
+	authPending bool
+	// The code of the response to the current command, for metrics.
+	responseCode int
+	// The first byte of the response to the current command, and
+	// whether its status line has been sent, for DailyQuota.
+	status     byte
+	statusSent bool
+	// Set by XFEATURE COMPRESS GZIP.
+	gzipOverview   bool
+	gzipTerminator bool
+
+	// Rate limits for the session, and its user's once logged in.
+	commands, bytes *bucket
+	limits          *userLimits
 }
 
 // The Server handle.
 type Server struct {
-	// Handlers are dispatched by command name.
+	// Handlers are dispatched by lower case command name.  Add
+	// to it to support other commands.
 	Handlers map[string]Handler
 	// The backend (your code) that provides data
 	Backend Backend
+	// Authenticator, if set, checks AUTHINFO credentials, and
+	// sessions must authenticate before using commands other than
+	// the likes of CAPABILITIES and MODE READER.
+	Authenticator Authenticator
+	// ACL, if set, limits which groups each session may read and
+	// post to.
+	ACL ACL
+	// Validator, if set, checks articles before they're handed to
+	// the backend.
+	Validator *Validator
+	// CompressRequiresAuth refuses COMPRESS until the session has
+	// authenticated.
+	CompressRequiresAuth bool
+	// CompressOverview offers compressed overview responses via
+	// XZVER and XFEATURE COMPRESS GZIP.
+	CompressOverview bool
+	// Capabilities maps command names to the lines they add to
+	// CAPABILITIES.  A command's lines are only advertised while it
+	// has a handler.  NewServer fills in the built-in commands.
+	Capabilities map[string]Capability
+	// Mode is the kind of service offered: ReaderMode (the default),
+	// TransitMode or ModeSwitching.
+	Mode Mode
+	// FeedPeers are the addresses of peers feeding articles.  Their
+	// sessions stay in transit mode.
+	FeedPeers []*net.IPNet
+	// ProxyProtocol lists the load balancers trusted to relay
+	// connections to Serve and ServeTLS.  Connections from them must
+	// start with a PROXY protocol (version 1 or 2) header, and the
+	// client address it gives is used in place of the proxy's.  The
+	// header precedes any TLS handshake, so use ServeTLS rather than
+	// a tls.NewListener for implicit TLS.
+	ProxyProtocol []*net.IPNet
+	// TLSConfig, if set, enables STARTTLS, and is used for the
+	// connections accepted by ServeTLS.
+	TLSConfig *tls.Config
+	// Logger receives the server's logs, with attributes
+	// identifying the session.  Commands are logged at debug level,
+	// with credentials redacted.  If nil, slog's default logger is
+	// used.
+	Logger *slog.Logger
+	// Metrics, if set, receives counts of what the server does.
+	Metrics Metrics
+	// SASLMechanisms are offered by AUTHINFO SASL.  NewServer sets
+	// up PLAIN.
+	SASLMechanisms []SASLMechanism
+	// MaxSessions, if positive, limits the number of concurrent
+	// sessions.  Connections beyond it are refused with a 400.
+	MaxSessions int
+	// MaxHostSessions, if positive, limits the number of concurrent
+	// sessions from one host.  Connections beyond it are refused
+	// with a 502.  Hosts are grouped into networks by
+	// HostPrefixIPv4 and HostPrefixIPv6 bits, if set.
+	MaxHostSessions int
+	HostPrefixIPv4  int
+	HostPrefixIPv6  int
+	// CommandRate and ByteRate throttle each session's commands
+	// and the bytes sent to it.  With RateByUser, authenticated
+	// sessions share their user's limits instead.
+	CommandRate Rate
+	ByteRate    Rate
+	RateByUser  bool
+	// DailyQuota, if positive, limits the bytes of articles and
+	// overview data sent to each authenticated user per (UTC) day.
+	// Once it's used up ARTICLE, BODY, HEAD and the overview
+	// commands are refused.
+	DailyQuota int64
 	// The currently selected group.
 	group *nntp.Group
+
+	middleware []Middleware
+	limits     limiter
+}
+
+// Use adds middleware that wraps every command's handler.  The first
+// added is the outermost.
+func (s *Server) Use(mw ...Middleware) {
+	s.middleware = append(s.middleware, mw...)
 }
 
 // NewServer builds a new server handle request to a backend.
 func NewServer(backend Backend) *Server {
 	rv := Server{
-		Handlers: make(map[string]Handler),
-		Backend:  backend,
+		Handlers:     make(map[string]Handler),
+		Backend:      backend,
+		Capabilities: defaultCapabilities(),
+
+		SASLMechanisms: []SASLMechanism{PlainMechanism{}},
 	}
 	rv.Handlers[""] = handleDefault
 	rv.Handlers["quit"] = handleQuit
+	rv.Handlers["date"] = handleDate
+	rv.Handlers["help"] = handleHelp
 	rv.Handlers["group"] = handleGroup
 	rv.Handlers["list"] = handleList
 	rv.Handlers["head"] = handleHead
@@ -120,70 +271,156 @@ func NewServer(backend Backend) *Server {
 	rv.Handlers["article"] = handleArticle
 	rv.Handlers["post"] = handlePost
 	rv.Handlers["ihave"] = handleIHave
+	rv.Handlers["check"] = handleCheck
+	rv.Handlers["takethis"] = handleTakeThis
 	rv.Handlers["capabilities"] = handleCap
 	rv.Handlers["mode"] = handleMode
 	rv.Handlers["authinfo"] = handleAuthInfo
 	rv.Handlers["newgroups"] = handleNewGroups
 	rv.Handlers["over"] = handleOver
 	rv.Handlers["xover"] = handleOver
+	rv.Handlers["compress"] = handleCompress
+	rv.Handlers["starttls"] = handleStartTLS
+	rv.Handlers["xzver"] = handleXZVer
+	rv.Handlers["xfeature"] = handleXFeature
 	return &rv
 }
 
-func (e *NNTPError) Error() string {
-	return fmt.Sprintf("%d %s", e.Code, e.Msg)
-}
-
-func (s *session) dispatchCommand(cmd string, args []string,
+func (s *Session) dispatchCommand(cmd string, args []string,
 	c *textproto.Conn) (err error) {
 
-	handler, found := s.server.Handlers[strings.ToLower(cmd)]
+	cmd = strings.ToLower(cmd)
+	handler, found := s.server.Handlers[cmd]
+	switch {
+	case found && !s.modeAllows(cmd):
+		handler = func([]string, *Session, *textproto.Conn) error {
+			return ErrCommandUnavailable
+		}
+	case found && s.authRequired(cmd):
+		handler = func([]string, *Session, *textproto.Conn) error {
+			return ErrNotAuthenticated
+		}
+	case found && s.overQuota(cmd):
+		handler = func([]string, *Session, *textproto.Conn) error {
+			return errQuotaExceeded
+		}
+	}
 	if !found {
 		handler, found = s.server.Handlers[""]
 		if !found {
 			panic("No default handler.")
 		}
 	}
-	return handler(args, s, c)
+	for i := len(s.server.middleware) - 1; i >= 0; i-- {
+		handler = s.server.middleware[i](handler)
+	}
+	s.command = cmd
+	// A TAKETHIS article follows the command unasked, so it has to be
+	// read even if the command is refused, or its lines would be taken
+	// for commands.
+	s.articlePending = cmd == "takethis"
+	err = handler(args, s, c)
+	if s.articlePending {
+		s.articlePending = false
+		if _, rerr := c.ReadDotBytes(); rerr != nil {
+			return rerr
+		}
+		var ne *NNTPError
+		if errors.As(err, &ne) && ne.Code != ErrNotAuthenticated.Code && len(args) == 1 {
+			err = &NNTPError{Code: 439, Msg: args[0]}
+		}
+	}
+	return err
+}
+
+// Command returns the name of the command being handled, in lower
+// case.
+func (s *Session) Command() string {
+	return s.command
 }
 
 // Process an NNTP session.
 func (s *Server) Process(nc net.Conn) {
-	defer nc.Close()
-	c := textproto.NewConn(nc)
-
-	sess := &session{
-		server:  s,
-		backend: s.Backend,
-		group:   nil,
+	sess := &Session{
+		server:     s,
+		backend:    s.Backend,
+		group:      nil,
+		remoteAddr: nc.RemoteAddr(),
+		Values:     map[string]interface{}{},
+		commands:   newBucket(s.CommandRate),
+		bytes:      newBucket(s.ByteRate),
+	}
+	if tc, ok := nc.(*tls.Conn); ok {
+		sess.tlsConn = tc
 	}
+	if s.Metrics != nil {
+		s.Metrics.SessionStarted()
+		defer s.Metrics.SessionEnded()
+		nc = &meteredConn{Conn: nc, m: s.Metrics}
+	}
+	if sess.bytes != nil || s.RateByUser {
+		nc = &limitedConn{Conn: nc, s: sess}
+	}
+	sess.setConn(nc)
+	sess.startLog()
+	sess.log().Debug("session started")
+	defer func() { sess.log().Info("session ended") }()
+	sess.feedPeer = s.isFeedPeer(sess.remoteAddr)
+	sess.reader = sess.startsInReader()
+	defer func() { sess.conn.Close() }()
+
+	host := s.hostKey(sess.remoteAddr)
+	if err := s.admit(host); err != nil {
+		sess.log().Info("refusing session", "err", err)
+		sess.conn.PrintfLine(err.Error())
+		return
+	}
+	defer s.release(host)
+	defer func() {
+		if sess.limits != nil {
+			s.releaseUser(sess.user)
+		}
+	}()
 
-	c.PrintfLine("200 Hello!")
+	sess.conn.PrintfLine("200 Hello!")
 	for {
+		c := sess.conn
 		l, err := c.ReadLine()
 		if err != nil {
-			log.Printf("Error reading from client, dropping conn: %v", err)
+			if err != io.EOF {
+				sess.log().Info("error reading from client, dropping conn",
+					"err", err)
+			}
 			return
 		}
+		time.Sleep(sess.commandBucket().take(1))
 		cmd := strings.Split(l, " ")
-		log.Printf("Got cmd:  %+v", cmd)
+		sess.log().Debug("command", "line", redact(l))
 		args := []string{}
 		if len(cmd) > 1 {
 			args = cmd[1:]
 		}
+		start := time.Now()
+		sess.responseCode = 0
+		sess.status, sess.statusSent = 0, false
 		err = sess.dispatchCommand(cmd[0], args, c)
-		if err != nil {
-			_, isNNTPError := err.(*NNTPError)
-			switch {
-			case err == io.EOF:
-				// Drop this connection silently. They hung up
-				return
-			case isNNTPError:
-				c.PrintfLine(err.Error())
-			default:
-				log.Printf("Error dispatching command, dropping conn: %v",
-					err)
-				return
-			}
+		var nntpErr *NNTPError
+		if err != nil && err != io.EOF && errors.As(err, &nntpErr) {
+			c.PrintfLine(nntpErr.Error())
+			err = nil
+		}
+		if s.Metrics != nil {
+			s.Metrics.Command(sess.commandLabel(cmd[0]), sess.responseCode,
+				time.Since(start))
+		}
+		switch {
+		case err == io.EOF:
+			// Drop this connection silently. They hung up
+			return
+		case err != nil:
+			sess.log().Warn("error dispatching command, dropping conn",
+				"command", sess.commandLabel(cmd[0]), "err", err)
+			return
 		}
 	}
 }
@@ -219,30 +456,122 @@ func parseRange(spec string) (low, high int64) {
    :lines metadata item
 */
 
-func handleOver(args []string, s *session, c *textproto.Conn) error {
-	if s.group == nil {
-		return ErrNoGroupSelected
-	}
-	from, to := parseRange(args[0])
-	articles, err := s.backend.GetArticles(s.group, from, to)
+func handleOver(args []string, s *Session, c *textproto.Conn) error {
+	articles, err := s.overview(args)
 	if err != nil {
 		return err
 	}
 	c.PrintfLine("224 here it comes")
+	if s.gzipOverview {
+		return writeGzipOverview(s, c, articles)
+	}
 	dw := c.DotWriter()
 	defer dw.Close()
+	return writeOverview(dw, articles)
+}
+
+// overview fetches the articles for an OVER or XZVER range.
+func (s *Session) overview(args []string) ([]NumberedArticle, error) {
+	if s.group == nil {
+		return nil, ErrNoGroupSelected
+	}
+	// With no range, it's the current article (RFC 3977 section 8.3).
+	var from, to int64
+	switch {
+	case len(args) > 0:
+		from, to = parseRange(args[0])
+	case s.article == 0:
+		return nil, ErrNoCurrentArticle
+	default:
+		from, to = s.article, s.article
+	}
+	start := time.Now()
+	articles, err := s.backend.GetArticles(s.group, from, to)
+	s.timed("GetArticles", start, err)
+	return articles, err
+}
+
+func writeOverview(w io.Writer, articles []NumberedArticle) error {
 	for _, a := range articles {
-		fmt.Fprintf(dw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n", a.Num,
+		_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\r\n", a.Num,
 			a.Article.Header.Get("Subject"),
 			a.Article.Header.Get("From"),
 			a.Article.Header.Get("Date"),
 			a.Article.Header.Get("Message-Id"),
 			a.Article.Header.Get("References"),
 			a.Article.Bytes, a.Article.Lines)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// writeGzipOverview sends the dot-terminated overview block as a
+// single zlib stream, followed by a bare terminator line if the client
+// asked for one.
+func writeGzipOverview(s *Session, c *textproto.Conn,
+	articles []NumberedArticle) error {
+
+	zw := zlib.NewWriter(c.W)
+	tw := textproto.NewWriter(bufio.NewWriter(zw))
+	dw := tw.DotWriter()
+	if err := writeOverview(dw, articles); err != nil {
+		return err
+	}
+	if err := dw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if s.gzipTerminator {
+		c.W.WriteString(".\r\n")
+	}
+	return c.W.Flush()
+}
+
+func handleXZVer(args []string, s *Session, c *textproto.Conn) error {
+	if !s.server.CompressOverview {
+		return ErrUnknownCommand
+	}
+	articles, err := s.overview(args)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeOverview(&buf, articles); err != nil {
+		return err
+	}
+	c.PrintfLine("224 compressed data follows")
+	dw := c.DotWriter()
+	defer dw.Close()
+	return nntp.EncodeXZVer(dw, buf.Bytes())
+}
+
+// handleXFeature supports XFEATURE COMPRESS GZIP [TERMINATOR], after
+// which overview responses are zlib compressed.
+func handleXFeature(args []string, s *Session, c *textproto.Conn) error {
+	if !s.server.CompressOverview {
+		return ErrUnknownCommand
+	}
+	if len(args) < 2 || len(args) > 3 ||
+		strings.ToLower(args[0]) != "compress" ||
+		strings.ToLower(args[1]) != "gzip" {
+		return ErrSyntax
+	}
+	terminator := false
+	if len(args) == 3 {
+		if strings.ToLower(args[2]) != "terminator" {
+			return ErrSyntax
+		}
+		terminator = true
+	}
+	s.gzipOverview = true
+	s.gzipTerminator = terminator
+	return c.PrintfLine("290 feature enabled")
+}
+
 func handleListOverviewFmt(c *textproto.Conn) error {
 	err := c.PrintfLine("215 Order of fields in overview database.")
 	if err != nil {
@@ -260,7 +589,7 @@ References:
 	return err
 }
 
-func handleList(args []string, s *session, c *textproto.Conn) error {
+func handleList(args []string, s *Session, c *textproto.Conn) error {
 	ltype := "active"
 	if len(args) > 0 {
 		ltype = strings.ToLower(args[0])
@@ -270,7 +599,9 @@ func handleList(args []string, s *session, c *textproto.Conn) error {
 		return handleListOverviewFmt(c)
 	}
 
+	start := time.Now()
 	groups, err := s.backend.ListGroups(-1)
+	s.timed("ListGroups", start, err)
 	if err != nil {
 		return err
 	}
@@ -278,10 +609,13 @@ func handleList(args []string, s *session, c *textproto.Conn) error {
 	dw := c.DotWriter()
 	defer dw.Close()
 	for _, g := range groups {
+		if !s.canRead(g.Name) {
+			continue
+		}
 		switch ltype {
 		case "active":
 			fmt.Fprintf(dw, "%s %d %d %v\r\n",
-				g.Name, g.High, g.Low, g.Posting)
+				g.Name, g.High, g.Low, s.postingStatus(g))
 		case "newsgroups":
 			fmt.Fprintf(dw, "%s %s\r\n", g.Name, g.Description)
 		}
@@ -290,43 +624,131 @@ func handleList(args []string, s *session, c *textproto.Conn) error {
 	return nil
 }
 
-func handleNewGroups(args []string, s *session, c *textproto.Conn) error {
+func handleNewGroups(args []string, s *Session, c *textproto.Conn) error {
 	c.PrintfLine("231 list of newsgroups follows")
 	c.PrintfLine(".")
 	return nil
 }
 
-func handleDefault(args []string, s *session, c *textproto.Conn) error {
+func handleDefault(args []string, s *Session, c *textproto.Conn) error {
 	return ErrUnknownCommand
 }
 
-func handleQuit(args []string, s *session, c *textproto.Conn) error {
+func handleQuit(args []string, s *Session, c *textproto.Conn) error {
 	c.PrintfLine("205 bye")
 	return io.EOF
 }
 
-func handleGroup(args []string, s *session, c *textproto.Conn) error {
+/*
+   Syntax
+     DATE
+
+   Responses
+     111 yyyymmddhhmmss    Server date and time
+
+   See https://datatracker.ietf.org/doc/html/rfc3977#section-7.1
+*/
+
+func handleDate(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) != 0 {
+		return ErrSyntax
+	}
+	return c.PrintfLine("111 %s", now().UTC().Format("20060102150405"))
+}
+
+/*
+   Syntax
+     HELP
+
+   Responses
+     100    Help text follows (multi-line)
+
+   See https://datatracker.ietf.org/doc/html/rfc3977#section-7.2
+*/
+
+func handleHelp(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) != 0 {
+		return ErrSyntax
+	}
+	var cmds []string
+	for cmd := range s.server.Handlers {
+		if cmd != "" {
+			cmds = append(cmds, strings.ToUpper(cmd))
+		}
+	}
+	sort.Strings(cmds)
+	c.PrintfLine("100 Legal commands")
+	dw := c.DotWriter()
+	defer dw.Close()
+	for _, cmd := range cmds {
+		if _, err := fmt.Fprintf(dw, "  %s\r\n", cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleGroup(args []string, s *Session, c *textproto.Conn) error {
 	if len(args) < 1 {
 		return ErrNoSuchGroup
 	}
+	if !s.canRead(args[0]) {
+		return s.accessDenied()
+	}
 
+	start := time.Now()
 	group, err := s.backend.GetGroup(args[0])
+	s.timed("GetGroup", start, err)
 	if err != nil {
 		return err
 	}
 
 	s.group = group
+	s.article = 0
+	if group.Count > 0 {
+		s.article = group.Low
+	}
 
 	c.PrintfLine("211 %d %d %d %s",
 		group.Count, group.Low, group.High, group.Name)
 	return nil
 }
 
-func (s *session) getArticle(args []string) (*nntp.Article, error) {
+// getArticle finds the article named by a message-id or article
+// number argument, or the current article if there's no argument.  It
+// returns the article number, which is 0 when looked up by
+// message-id.
+func (s *Session) getArticle(args []string) (int64, *nntp.Article, error) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "<") {
+		if !nntp.ValidMessageID(args[0]) {
+			return 0, nil, ErrSyntax
+		}
+		article, err := s.fetchArticle(s.group, args[0])
+		if err == nil && !s.canReadArticle(article) {
+			return 0, nil, ErrInvalidMessageID
+		}
+		return 0, article, err
+	}
+
 	if s.group == nil {
-		return nil, ErrNoGroupSelected
+		return 0, nil, ErrNoGroupSelected
+	}
+	num := s.article
+	if len(args) > 0 {
+		var err error
+		num, err = strconv.ParseInt(args[0], 10, 64)
+		if err != nil || num < 1 {
+			return 0, nil, ErrSyntax
+		}
+	} else if num == 0 {
+		return 0, nil, ErrNoCurrentArticle
+	}
+	article, err := s.fetchArticle(s.group, strconv.FormatInt(num, 10))
+	if err != nil {
+		return 0, nil, err
 	}
-	return s.backend.GetArticle(s.group, args[0])
+	s.article = num
+	return num, article, nil
 }
 
 /*
@@ -351,18 +773,16 @@ func (s *session) getArticle(args []string) (*nntp.Article, error) {
      420                   Current article number is invalid
 */
 
-func handleHead(args []string, s *session, c *textproto.Conn) error {
-	article, err := s.getArticle(args)
+func handleHead(args []string, s *Session, c *textproto.Conn) error {
+	num, article, err := s.getArticle(args)
 	if err != nil {
 		return err
 	}
-	c.PrintfLine("221 1 %s", article.MessageID())
+	c.PrintfLine("221 %d %s", num, article.MessageID())
 	dw := c.DotWriter()
 	defer dw.Close()
-	for k, v := range article.Header {
-		fmt.Fprintf(dw, "%s: %s\r\n", k, v[0])
-	}
-	return nil
+	_, err = article.WriteHeader(dw)
+	return err
 }
 
 /*
@@ -393,12 +813,12 @@ func handleHead(args []string, s *session, c *textproto.Conn) error {
      message-id    Article message-id
 */
 
-func handleBody(args []string, s *session, c *textproto.Conn) error {
-	article, err := s.getArticle(args)
+func handleBody(args []string, s *Session, c *textproto.Conn) error {
+	num, article, err := s.getArticle(args)
 	if err != nil {
 		return err
 	}
-	c.PrintfLine("222 1 %s", article.MessageID())
+	c.PrintfLine("222 %d %s", num, article.MessageID())
 	dw := c.DotWriter()
 	defer dw.Close()
 	_, err = io.Copy(dw, article.Body)
@@ -433,22 +853,15 @@ func handleBody(args []string, s *session, c *textproto.Conn) error {
      message-id    Article message-id
 */
 
-func handleArticle(args []string, s *session, c *textproto.Conn) error {
-	article, err := s.getArticle(args)
+func handleArticle(args []string, s *Session, c *textproto.Conn) error {
+	num, article, err := s.getArticle(args)
 	if err != nil {
 		return err
 	}
-	c.PrintfLine("220 1 %s", article.MessageID())
+	c.PrintfLine("220 %d %s", num, article.MessageID())
 	dw := c.DotWriter()
 	defer dw.Close()
-
-	for k, v := range article.Header {
-		fmt.Fprintf(dw, "%s: %s\r\n", k, v[0])
-	}
-
-	fmt.Fprintln(dw, "")
-
-	_, err = io.Copy(dw, article.Body)
+	_, err = article.WriteTo(dw)
 	return err
 }
 
@@ -467,20 +880,21 @@ func handleArticle(args []string, s *session, c *textproto.Conn) error {
      441    Posting failed
 */
 
-func handlePost(args []string, s *session, c *textproto.Conn) error {
-	if !s.backend.AllowPost() {
+func handlePost(args []string, s *Session, c *textproto.Conn) error {
+	if !s.backend.AllowPost() || !s.canPost("") {
 		return ErrPostingNotPermitted
 	}
 
 	c.PrintfLine("340 Go ahead")
-	var err error
-	var article nntp.Article
-	article.Header, err = c.ReadMIMEHeader()
+	article, err := nntp.ReadArticle(c.DotReader())
 	if err != nil {
 		return ErrPostingFailed
 	}
-	article.Body = c.DotReader()
-	err = s.backend.Post(&article)
+	err = s.checkPost(article)
+	if err == nil {
+		err = s.post(article)
+	}
+	s.articleOffered(err)
 	if err != nil {
 		return err
 	}
@@ -488,25 +902,64 @@ func handlePost(args []string, s *session, c *textproto.Conn) error {
 	return nil
 }
 
-func handleIHave(args []string, s *session, c *textproto.Conn) error {
+// checkPost applies the ACL and Validator to a posted article.
+func (s *Session) checkPost(article *nntp.Article) error {
+	for _, g := range strings.Split(article.Header.Get("Newsgroups"), ",") {
+		if g = strings.TrimSpace(g); g != "" && !s.canPost(g) {
+			return &NNTPError{Code: 441, Msg: "Posting to " + g + " not permitted"}
+		}
+	}
+	if v := s.server.Validator; v != nil {
+		v.Inject(article, postingHost(s.remoteAddr))
+		return v.Check(article)
+	}
+	return nil
+}
+
+// fetchArticle gets an article from the backend.
+func (s *Session) fetchArticle(group *nntp.Group, id string) (*nntp.Article, error) {
+	start := time.Now()
+	article, err := s.backend.GetArticle(group, id)
+	s.timed("GetArticle", start, err)
+	return article, err
+}
+
+// post hands an article to the backend.
+func (s *Session) post(article *nntp.Article) error {
+	start := time.Now()
+	err := s.backend.Post(article)
+	s.timed("Post", start, err)
+	return err
+}
+
+func handleIHave(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) < 1 || !nntp.ValidMessageID(args[0]) {
+		return ErrSyntax
+	}
 	if !s.backend.AllowPost() {
 		return ErrNotWanted
 	}
 
 	// XXX:  See if we have it.
-	article, err := s.backend.GetArticle(nil, args[0])
+	article, err := s.fetchArticle(nil, args[0])
 	if article != nil {
 		return ErrNotWanted
 	}
 
 	c.PrintfLine("335 send it")
-	article = &nntp.Article{}
-	article.Header, err = c.ReadMIMEHeader()
+	article, err = nntp.ReadArticle(c.DotReader())
 	if err != nil {
 		return ErrPostingFailed
 	}
-	article.Body = c.DotReader()
-	err = s.backend.Post(article)
+	if v := s.server.Validator; v != nil {
+		if problem := v.problem(article); problem != "" {
+			err = &NNTPError{Code: 437, Msg: problem}
+		}
+	}
+	if err == nil {
+		err = s.post(article)
+	}
+	s.articleOffered(err)
 	if err != nil {
 		return err
 	}
@@ -514,56 +967,139 @@ func handleIHave(args []string, s *session, c *textproto.Conn) error {
 	return nil
 }
 
-func handleCap(args []string, s *session, c *textproto.Conn) error {
-	c.PrintfLine("101 Capability list:")
-	dw := c.DotWriter()
-	defer dw.Close()
+/*
+   Syntax
+     AUTHINFO USER username
+     AUTHINFO PASS password
+
+   Responses
+     281    Authentication accepted
+     381    Password required
+     481    Authentication failed/rejected
+     482    Authentication commands issued out of sequence
+     502    Command unavailable
 
-	fmt.Fprintf(dw, "VERSION 2\n")
-	fmt.Fprintf(dw, "READER\n")
-	if s.backend.AllowPost() {
-		fmt.Fprintf(dw, "POST\n")
-		fmt.Fprintf(dw, "IHAVE\n")
+   See https://datatracker.ietf.org/doc/html/rfc4643#section-2.3
+*/
+
+func handleAuthInfo(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) < 2 {
+		return ErrSyntax
+	}
+	if s.authenticated {
+		return ErrCommandUnavailable
 	}
-	fmt.Fprintf(dw, "OVER\n")
-	fmt.Fprintf(dw, "XOVER\n")
-	fmt.Fprintf(dw, "LIST ACTIVE NEWSGROUPS OVERVIEW.FMT\n")
-	return nil
-}
 
-func handleMode(args []string, s *session, c *textproto.Conn) error {
-	if s.backend.AllowPost() {
-		c.PrintfLine("200 Posting allowed")
-	} else {
-		c.PrintfLine("201 Posting prohibited")
+	// Any AUTHINFO but PASS abandons a pending USER.
+	user, pending := s.authUser, s.authPending
+	s.authUser, s.authPending = "", false
+
+	switch strings.ToLower(args[0]) {
+	case "sasl":
+		return handleAuthInfoSASL(args, s, c)
+	case "user":
+		if len(args) != 2 {
+			return ErrSyntax
+		}
+		if s.server.Authenticator == nil {
+			return ErrCommandUnavailable
+		}
+		s.authUser, s.authPending = args[1], true
+		return c.PrintfLine("381 Password required")
+	case "pass":
+		if !pending {
+			return ErrAuthOutOfSequence
+		}
+		// Passwords may contain spaces.
+		pass := strings.Join(args[1:], " ")
+		if err := s.server.Authenticator.Authenticate(user, pass); err != nil {
+			s.authFailed()
+			return err
+		}
+		if err := s.login(user); err != nil {
+			return err
+		}
+		return c.PrintfLine("281 Authentication accepted")
 	}
-	return nil
+	return ErrSyntax
 }
 
-func handleAuthInfo(args []string, s *session, c *textproto.Conn) error {
-	if len(args) < 2 {
+/*
+   Syntax
+     STARTTLS
+
+   Responses
+     382    Continue with TLS negotiation
+     502    Command unavailable
+     580    Can not initiate TLS negotiation
+
+   See https://datatracker.ietf.org/doc/html/rfc4642
+*/
+
+// startTLSAllowed reports whether STARTTLS may be used now.  It can't
+// follow TLS, compression or authentication.
+func (s *Session) startTLSAllowed() bool {
+	return s.server.TLSConfig != nil && s.tlsConn == nil &&
+		!s.compressed && !s.authenticated
+}
+
+func handleStartTLS(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) != 0 {
 		return ErrSyntax
 	}
-	if strings.ToLower(args[0]) != "user" {
-		return ErrSyntax
+	if !s.startTLSAllowed() {
+		return ErrCommandUnavailable
 	}
-
-	if s.backend.Authorized() {
-		return c.PrintfLine("250 authenticated")
+	if err := c.PrintfLine("382 Continue with TLS negotiation"); err != nil {
+		return err
+	}
+	tc := tls.Server(s.netconn, s.server.TLSConfig)
+	if err := tc.Handshake(); err != nil {
+		return err
 	}
+	s.tlsConn = tc
+	s.setConn(tc)
+	// Nothing from before the negotiation carries over (RFC 4642
+	// section 2.2.2), so the session starts afresh.
+	s.authUser, s.authPending = "", false
+	s.group, s.article = nil, 0
+	s.gzipOverview, s.gzipTerminator = false, false
+	s.reader = s.startsInReader()
+	s.Values = map[string]interface{}{}
+	return nil
+}
+
+/*
+   Syntax
+     COMPRESS DEFLATE
 
-	c.PrintfLine("350 Continue")
-	a, err := c.ReadLine()
-	parts := strings.SplitN(a, " ", 3)
-	if strings.ToLower(parts[0]) != "authinfo" || strings.ToLower(parts[1]) != "pass" {
+   Responses
+     206    Compression active
+     403    Unable to activate compression
+     502    Command unavailable
+
+   See https://datatracker.ietf.org/doc/html/rfc8054
+*/
+
+// compressAllowed reports whether COMPRESS may be used now.
+func (s *Session) compressAllowed() bool {
+	return !s.compressed && (s.authenticated || !s.server.CompressRequiresAuth)
+}
+
+func handleCompress(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) != 1 || strings.ToLower(args[0]) != "deflate" {
 		return ErrSyntax
 	}
-	b, err := s.backend.Authenticate(args[1], parts[2])
-	if err == nil {
-		c.PrintfLine("250 authenticated")
-		if b != nil {
-			s.backend = b
-		}
+	if s.compressed {
+		return ErrCommandUnavailable
 	}
-	return err
+	if !s.compressAllowed() {
+		return ErrNotAuthenticated
+	}
+	if err := c.PrintfLine("206 Compression active"); err != nil {
+		return err
+	}
+	s.compressed = true
+	s.setConn(nntp.NewDeflateConn(s.netconn))
+	return nil
 }
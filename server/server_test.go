@@ -2,7 +2,13 @@ package nntpserver
 
 import (
 	"math"
+	"net"
+	"net/textproto"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dustin/go-nntp"
 )
 
 type rangeExpectation struct {
@@ -30,3 +36,160 @@ func TestRangeEmpty(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthInfo(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	s := NewServer(authBackend{})
+	s.Authenticator = testAuth
+	go s.Process(b)
+
+	c := textproto.NewConn(a)
+	if _, _, err := c.ReadCodeLine(200); err != nil {
+		t.Fatalf("Error reading greeting: %v", err)
+	}
+	steps := []struct {
+		cmd  string
+		code int
+	}{
+		{"GROUP misc.test", 480},
+		{"AUTHINFO PASS secret", 482},
+		{"AUTHINFO USER", 501},
+		{"AUTHINFO USER user", 381},
+		{"AUTHINFO PASS wrong", 481},
+		{"AUTHINFO PASS secret", 482},
+		{"AUTHINFO USER user", 381},
+		{"AUTHINFO PASS secret", 281},
+		{"AUTHINFO USER user", 502},
+		{"AUTHINFO SASL PLAIN", 502},
+	}
+	for _, step := range steps {
+		if err := c.PrintfLine("%s", step.cmd); err != nil {
+			t.Fatalf("Error sending %q: %v", step.cmd, err)
+		}
+		code, msg, err := c.ReadCodeLine(0)
+		if err != nil || code != step.code {
+			t.Fatalf("%q: expected %d, got %d %s (%v)",
+				step.cmd, step.code, code, msg, err)
+		}
+	}
+
+	c.PrintfLine("CAPABILITIES")
+	if _, _, err := c.ReadCodeLine(101); err != nil {
+		t.Fatalf("Error reading capabilities: %v", err)
+	}
+	caps, err := c.ReadDotLines()
+	if err != nil {
+		t.Fatalf("Error reading capabilities: %v", err)
+	}
+	for _, l := range caps {
+		if strings.HasPrefix(l, "AUTHINFO") || strings.HasPrefix(l, "SASL") {
+			t.Errorf("Advertised %q after authenticating", l)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	s := NewServer(authBackend{})
+	s.Handlers["xecho"] = func(args []string, s *Session, c *textproto.Conn) error {
+		return c.PrintfLine("280 %s", strings.Join(args, " "))
+	}
+	var seen []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(args []string, s *Session, c *textproto.Conn) error {
+				seen = append(seen, name+" "+s.Command())
+				return next(args, s, c)
+			}
+		}
+	}
+	s.Use(trace("outer"), trace("inner"))
+	go s.Process(b)
+
+	c := textproto.NewConn(a)
+	c.ReadCodeLine(200)
+	c.PrintfLine("XECHO hello there")
+	if _, msg, err := c.ReadCodeLine(280); err != nil || msg != "hello there" {
+		t.Fatalf("Unexpected XECHO response: %q, %v", msg, err)
+	}
+	exp := []string{"outer xecho", "inner xecho"}
+	if strings.Join(seen, ",") != strings.Join(exp, ",") {
+		t.Errorf("Expected middleware calls %q, got %q", exp, seen)
+	}
+}
+
+func TestSessionAPI(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	s := NewServer(groupBackend{})
+	s.Authenticator = testAuth
+	s.Handlers["xwho"] = func(args []string, s *Session, c *textproto.Conn) error {
+		n, _ := s.Values["calls"].(int)
+		s.Values["calls"] = n + 1
+		group := ""
+		if g := s.Group(); g != nil {
+			group = g.Name
+		}
+		return c.PrintfLine("280 %s %s %d %d %v", s.User(), group,
+			s.ArticleNumber(), n+1, s.TLSState() != nil)
+	}
+	go s.Process(b)
+
+	c := textproto.NewConn(a)
+	c.ReadCodeLine(200)
+	for _, step := range []struct{ cmd, exp string }{
+		{"AUTHINFO USER user", ""},
+		{"AUTHINFO PASS secret", ""},
+		{"XWHO", "user  0 1 false"},
+		{"GROUP misc.test", ""},
+		{"XWHO", "user misc.test 3 2 false"},
+	} {
+		c.PrintfLine("%s", step.cmd)
+		_, msg, err := c.ReadCodeLine(0)
+		if err != nil {
+			t.Fatalf("%q: %v", step.cmd, err)
+		}
+		if step.exp != "" && msg != step.exp {
+			t.Errorf("%q: expected %q, got %q", step.cmd, step.exp, msg)
+		}
+	}
+}
+
+type groupBackend struct {
+	authBackend
+}
+
+func (groupBackend) GetGroup(name string) (*nntp.Group, error) {
+	return &nntp.Group{Name: name, Count: 5, Low: 3, High: 7}, nil
+}
+
+func TestRedact(t *testing.T) {
+	for _, e := range []struct{ in, exp string }{
+		{"GROUP misc.test", "GROUP misc.test"},
+		{"AUTHINFO USER user", "AUTHINFO USER user"},
+		{"authinfo pass my secret", "authinfo pass [redacted]"},
+		{"AUTHINFO SASL PLAIN", "AUTHINFO SASL PLAIN"},
+		{"AUTHINFO SASL PLAIN AHVzZXIAc2VjcmV0", "AUTHINFO SASL PLAIN [redacted]"},
+	} {
+		if got := redact(e.in); got != e.exp {
+			t.Errorf("redact(%q) = %q, expected %q", e.in, got, e.exp)
+		}
+	}
+}
+
+func TestDateAndHelp(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	s := NewServer(authBackend{})
+	// Both are available before authenticating.
+	s.Authenticator = testAuth
+	transcript(t, s, "192.0.2.1:119", []string{
+		"DATE", "111 20260102030405",
+		"DATE now", "501",
+		"HELP", "100",
+		"GROUP misc.test", "480",
+	})
+}
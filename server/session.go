@@ -0,0 +1,65 @@
+package nntpserver
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/dustin/go-nntp"
+)
+
+// Group returns the currently selected group, or nil.
+func (s *Session) Group() *nntp.Group {
+	return s.group
+}
+
+// SetGroup selects a group, with no current article.
+func (s *Session) SetGroup(g *nntp.Group) {
+	s.group = g
+	s.article = 0
+}
+
+// ArticleNumber returns the current article number, or 0 if there
+// isn't one.
+func (s *Session) ArticleNumber() int64 {
+	return s.article
+}
+
+// SetArticleNumber sets the current article number.
+func (s *Session) SetArticleNumber(n int64) {
+	s.article = n
+}
+
+// Backend returns the session's backend.
+func (s *Session) Backend() Backend {
+	return s.backend
+}
+
+// SetBackend swaps the backend used for the rest of the session.
+func (s *Session) SetBackend(b Backend) {
+	s.backend = b
+}
+
+// RemoteAddr returns the client's address.
+func (s *Session) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+// TLSState returns the state of the session's TLS connection, or nil
+// if it isn't using TLS.
+func (s *Session) TLSState() *tls.ConnectionState {
+	if s.tlsConn == nil {
+		return nil
+	}
+	st := s.tlsConn.ConnectionState()
+	return &st
+}
+
+// User returns the authenticated user, or "" before authentication.
+func (s *Session) User() string {
+	return s.user
+}
+
+// Authenticated reports whether the session has authenticated.
+func (s *Session) Authenticated() bool {
+	return s.authenticated
+}
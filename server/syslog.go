@@ -0,0 +1,83 @@
+//go:build !windows && !plan9
+
+package nntpserver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"log/syslog"
+)
+
+// NewSyslogLogger returns a logger writing to the local syslog daemon
+// with the given tag.  Records below level are dropped, and the rest
+// are sent with the matching syslog severity.
+func NewSyslogLogger(tag string, level slog.Leveler) (*slog.Logger, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_NEWS, tag)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(&syslogHandler{w: w, level: level}), nil
+}
+
+// syslogHandler formats records as text and writes each at its own
+// severity.
+type syslogHandler struct {
+	w     *syslog.Writer
+	level slog.Leveler
+	// WithAttrs and WithGroup calls, replayed on each record's text
+	// handler.
+	with []func(slog.Handler) slog.Handler
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	var th slog.Handler = slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: h.level,
+		// syslog adds its own timestamp.
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	for _, f := range h.with {
+		th = f(th)
+	}
+	if err := th.Handle(ctx, r); err != nil {
+		return err
+	}
+	msg := buf.String()
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.w.Err(msg)
+	case r.Level >= slog.LevelWarn:
+		return h.w.Warning(msg)
+	case r.Level >= slog.LevelInfo:
+		return h.w.Info(msg)
+	}
+	return h.w.Debug(msg)
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.extend(func(th slog.Handler) slog.Handler {
+		return th.WithAttrs(attrs)
+	})
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return h.extend(func(th slog.Handler) slog.Handler {
+		return th.WithGroup(name)
+	})
+}
+
+func (h *syslogHandler) extend(f func(slog.Handler) slog.Handler) slog.Handler {
+	with := make([]func(slog.Handler) slog.Handler, len(h.with), len(h.with)+1)
+	copy(with, h.with)
+	return &syslogHandler{w: h.w, level: h.level, with: append(with, f)}
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package nntpserver
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// NewSyslogLogger is not supported on this platform.
+func NewSyslogLogger(tag string, level slog.Leveler) (*slog.Logger, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,187 @@
+package nntpserver
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/dustin/go-nntp"
+)
+
+// A Mode is the kind of service a Server provides.
+type Mode int
+
+const (
+	// ReaderMode serves readers, with every command available.
+	ReaderMode = Mode(iota)
+	// TransitMode only accepts articles from peers, with IHAVE and
+	// streaming.
+	TransitMode
+	// ModeSwitching starts sessions in transit mode until the client
+	// sends MODE READER.
+	ModeSwitching
+)
+
+// Commands available in transit mode.
+var transitCommands = map[string]bool{
+	"ihave":    true,
+	"check":    true,
+	"takethis": true,
+}
+
+// isFeedPeer reports whether addr is one of the server's FeedPeers.
+func (s *Server) isFeedPeer(addr net.Addr) bool {
+	return containsAddr(s.FeedPeers, addr)
+}
+
+// transitOnly reports whether the session can never switch to reader
+// mode.
+func (s *Session) transitOnly() bool {
+	return s.feedPeer || s.server.Mode == TransitMode
+}
+
+// startsInReader reports whether the session starts in reader mode.
+func (s *Session) startsInReader() bool {
+	return s.server.Mode == ReaderMode && !s.feedPeer
+}
+
+// modeAllows reports whether cmd is available in the session's mode.
+func (s *Session) modeAllows(cmd string) bool {
+	return s.reader || publicCommands[cmd] || transitCommands[cmd]
+}
+
+func modeCapabilities(s *Session) []string {
+	switch {
+	case s.reader:
+		return []string{"READER"}
+	case !s.transitOnly():
+		return []string{"MODE-READER"}
+	}
+	return nil
+}
+
+func streamingCapabilities(s *Session) []string {
+	if !s.reader && s.backend.AllowPost() {
+		return []string{"STREAMING"}
+	}
+	return nil
+}
+
+/*
+   Syntax
+     MODE READER
+     MODE STREAM
+
+   Responses
+     200    Posting allowed
+     201    Posting prohibited
+     203    Streaming permitted
+     502    Reading service permanently unavailable
+
+   See https://datatracker.ietf.org/doc/html/rfc3977#section-5.3 and
+   https://datatracker.ietf.org/doc/html/rfc4644#section-2.3
+*/
+
+func handleMode(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) != 1 {
+		return ErrSyntax
+	}
+	switch strings.ToLower(args[0]) {
+	case "reader":
+		if s.transitOnly() {
+			return ErrCommandUnavailable
+		}
+		s.reader = true
+		if s.backend.AllowPost() && s.canPost("") {
+			return c.PrintfLine("200 Posting allowed")
+		}
+		return c.PrintfLine("201 Posting prohibited")
+	case "stream":
+		return c.PrintfLine("203 Streaming permitted")
+	}
+	return ErrSyntax
+}
+
+// wanted reports whether an offered article would be accepted.
+func (s *Session) wanted(id string) bool {
+	if !s.backend.AllowPost() {
+		return false
+	}
+	article, _ := s.fetchArticle(nil, id)
+	return article == nil
+}
+
+/*
+   Syntax
+     CHECK message-id
+
+   Responses
+     238 message-id   Send article to be transferred
+     431 message-id   Transfer not possible; try again later
+     438 message-id   Article not wanted
+
+   See https://datatracker.ietf.org/doc/html/rfc4644#section-2.4
+*/
+
+func handleCheck(args []string, s *Session, c *textproto.Conn) error {
+	if len(args) != 1 || !nntp.ValidMessageID(args[0]) {
+		return ErrSyntax
+	}
+	if !s.wanted(args[0]) {
+		return &NNTPError{Code: 438, Msg: args[0]}
+	}
+	return c.PrintfLine("238 %s", args[0])
+}
+
+/*
+   Syntax
+     TAKETHIS message-id
+
+   Responses
+     239 message-id   Article transferred OK
+     439 message-id   Transfer rejected; do not retry
+
+   See https://datatracker.ietf.org/doc/html/rfc4644#section-2.5
+*/
+
+func handleTakeThis(args []string, s *Session, c *textproto.Conn) error {
+	// The article follows regardless, so it has to be read before
+	// anything can be refused.  Only failing to read it leaves the
+	// stream out of step; any other problem refuses this article.
+	s.articlePending = false
+	raw, err := c.ReadDotBytes()
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || !nntp.ValidMessageID(args[0]) {
+		return ErrSyntax
+	}
+	id := args[0]
+	article, err := nntp.ReadArticle(bytes.NewReader(raw))
+	if err == nil {
+		err = s.takeThis(id, article)
+	}
+	s.articleOffered(err)
+	if err != nil {
+		var ne *NNTPError
+		if !errors.As(err, &ne) {
+			s.log().Warn("refusing article", "id", id, "err", err)
+		}
+		return &NNTPError{Code: 439, Msg: id}
+	}
+	return c.PrintfLine("239 %s", id)
+}
+
+func (s *Session) takeThis(id string, article *nntp.Article) error {
+	if !s.wanted(id) {
+		return ErrNotWanted
+	}
+	if v := s.server.Validator; v != nil {
+		if problem := v.problem(article); problem != "" {
+			return &NNTPError{Code: 437, Msg: problem}
+		}
+	}
+	return s.post(article)
+}
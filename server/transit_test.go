@@ -0,0 +1,201 @@
+package nntpserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-nntp"
+)
+
+type feedBackend struct {
+	authBackend
+	articles map[string]*nntp.Article
+}
+
+func (feedBackend) AllowPost() bool {
+	return true
+}
+
+func (b feedBackend) GetArticle(group *nntp.Group, id string) (*nntp.Article, error) {
+	if a, ok := b.articles[id]; ok {
+		return a, nil
+	}
+	return nil, ErrInvalidMessageID
+}
+
+func (b feedBackend) Post(a *nntp.Article) error {
+	b.articles[a.MessageID()] = a
+	return nil
+}
+
+func transcript(t *testing.T, s *Server, addr string, steps []string) {
+	a, b := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.Process(fakeAddrConn{b, addr})
+		close(done)
+	}()
+	defer func() {
+		a.Close()
+		<-done
+	}()
+
+	c := textproto.NewConn(a)
+	c.ReadCodeLine(200)
+	for i := 0; i+1 < len(steps); i += 2 {
+		c.PrintfLine("%s", steps[i])
+		line, err := c.ReadLine()
+		if err != nil {
+			t.Fatalf("%q: %v", steps[i], err)
+		}
+		if !strings.HasPrefix(line, steps[i+1]) {
+			t.Errorf("%q: expected %q, got %q", steps[i], steps[i+1], line)
+		}
+		switch line[:3] {
+		case "100", "101", "215", "220", "221", "222", "224":
+			c.ReadDotLines()
+		}
+	}
+}
+
+type fakeAddrConn struct {
+	net.Conn
+	addr string
+}
+
+func (f fakeAddrConn) RemoteAddr() net.Addr {
+	a, _ := net.ResolveTCPAddr("tcp", f.addr)
+	return a
+}
+
+func TestModeSwitching(t *testing.T) {
+	peers, _ := ParseNetworks("192.0.2.1")
+	s := NewServer(feedBackend{articles: map[string]*nntp.Article{
+		"<old@example.com>": {},
+	}})
+	s.Mode = ModeSwitching
+	s.FeedPeers = peers
+
+	transcript(t, s, "198.51.100.1:119", []string{
+		"GROUP misc.test", "502",
+		"CHECK <old@example.com>", "438 <old@example.com>",
+		"CHECK <new@example.com>", "238 <new@example.com>",
+		"MODE STREAM", "203",
+		"MODE READER", "200",
+		"LIST OVERVIEW.FMT", "215",
+	})
+	transcript(t, s, "192.0.2.1:119", []string{
+		"MODE READER", "502",
+		"LIST", "502",
+		"CHECK <new@example.com>", "238",
+	})
+}
+
+func TestTakeThis(t *testing.T) {
+	b := feedBackend{articles: map[string]*nntp.Article{}}
+	s := NewServer(b)
+	s.Mode = TransitMode
+
+	a, p := net.Pipe()
+	defer a.Close()
+	go s.Process(p)
+	c := textproto.NewConn(a)
+	c.ReadCodeLine(200)
+
+	article := "Message-Id: <x@example.com>\r\nNewsgroups: misc.test\r\n\r\nhi\r\n.\r\n"
+	// Both are sent before reading either response, as streaming
+	// peers do; the second is a duplicate.
+	go func() {
+		c.W.WriteString("TAKETHIS <x@example.com>\r\n" + article)
+		c.W.WriteString("TAKETHIS <x@example.com>\r\n" + article)
+		c.W.Flush()
+	}()
+	for _, exp := range []string{"239 <x@example.com>", "439 <x@example.com>"} {
+		if line, err := c.ReadLine(); err != nil || line != exp {
+			t.Fatalf("Expected %q, got %q (%v)", exp, line, err)
+		}
+	}
+	if _, ok := b.articles["<x@example.com>"]; !ok {
+		t.Errorf("Article wasn't stored")
+	}
+}
+
+// brokenFeed fails to store articles.
+type brokenFeed struct {
+	feedBackend
+}
+
+func (brokenFeed) Post(*nntp.Article) error {
+	return errors.New("disk full")
+}
+
+func TestTakeThisRefused(t *testing.T) {
+	b := feedBackend{articles: map[string]*nntp.Article{}}
+	s := NewServer(b)
+	s.Mode = TransitMode
+	malformed := " continuation without a header\r\n\r\nhi\r\n."
+	article := "Message-Id: <%s>\r\nNewsgroups: misc.test\r\n\r\nhi\r\n."
+
+	// A malformed article is refused, without losing track of the
+	// stream.
+	transcript(t, s, "192.0.2.1:119", []string{
+		"TAKETHIS <bad@example.com>\r\n" + malformed, "439 <bad@example.com>",
+		"TAKETHIS <ok@example.com>\r\n" + fmt.Sprintf(article, "ok@example.com"),
+		"239 <ok@example.com>",
+	})
+	if _, ok := b.articles["<bad@example.com>"]; ok {
+		t.Errorf("Malformed article was stored")
+	}
+	if _, ok := b.articles["<ok@example.com>"]; !ok {
+		t.Errorf("Article after the malformed one wasn't stored")
+	}
+
+	// As is one the backend fails to store, without dropping the
+	// connection.
+	s.Backend = brokenFeed{b}
+	transcript(t, s, "192.0.2.1:119", []string{
+		"TAKETHIS <new@example.com>\r\n" + fmt.Sprintf(article, "new@example.com"),
+		"439 <new@example.com>",
+		"CHECK <other@example.com>", "238",
+	})
+}
+
+func TestTakeThisRefusedBeforeReading(t *testing.T) {
+	b := feedBackend{articles: map[string]*nntp.Article{}}
+	s := NewServer(b)
+	s.Mode = TransitMode
+	s.Authenticator = testAuth
+	// None of the article's lines may be taken for commands.
+	article := "Message-Id: <%s>\r\nNewsgroups: misc.test\r\n\r\nQUIT\r\n."
+
+	transcript(t, s, "192.0.2.1:119", []string{
+		"TAKETHIS <x@example.com>\r\n" + fmt.Sprintf(article, "x@example.com"), "480",
+		"AUTHINFO USER user", "381",
+		"AUTHINFO PASS secret", "281",
+		"TAKETHIS <x@example.com>\r\n" + fmt.Sprintf(article, "x@example.com"),
+		"239 <x@example.com>",
+	})
+
+	// Refusals by middleware are reported as 439.
+	s.Authenticator = nil
+	s.Use(func(next Handler) Handler {
+		return func(args []string, s *Session, c *textproto.Conn) error {
+			if s.Command() == "takethis" {
+				return ErrPostingNotPermitted
+			}
+			return next(args, s, c)
+		}
+	})
+	transcript(t, s, "192.0.2.1:119", []string{
+		"TAKETHIS <y@example.com>\r\n" + fmt.Sprintf(article, "y@example.com"),
+		"439 <y@example.com>",
+		"CHECK <z@example.com>", "238",
+	})
+	if _, ok := b.articles["<y@example.com>"]; ok {
+		t.Errorf("Article refused by middleware was stored")
+	}
+}
@@ -0,0 +1,141 @@
+package nntpserver
+
+import (
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-nntp"
+)
+
+// A Validator checks articles received via POST and IHAVE against
+// RFC 5536, and adds the headers an injecting agent is responsible
+// for (RFC 5537) to articles received via POST.
+//
+// Validation is disabled unless Server.Validator is set.
+type Validator struct {
+	// PathIdentity names this server in Path, Message-ID and
+	// Injection-Info headers.  It should be a fully qualified domain
+	// name.
+	PathIdentity string
+	// MaxCrossposts is the maximum number of newsgroups an article
+	// may be posted to.  Zero means no limit.
+	MaxCrossposts int
+}
+
+// requiredHeaders are the headers every article must have once it's
+// been injected.
+var requiredHeaders = []string{
+	"From", "Newsgroups", "Subject", "Message-Id", "Date", "Path",
+}
+
+// uniqueHeaders may appear at most once.
+var uniqueHeaders = []string{
+	"From", "Newsgroups", "Subject", "Message-Id", "Date", "Path",
+	"Injection-Info", "References", "Followup-To",
+}
+
+// Inject adds Message-ID, Date, Path and Injection-Info headers to an
+// article that lacks them.  postingHost identifies the client that
+// submitted the article and may be empty.
+func (v *Validator) Inject(a *nntp.Article, postingHost string) {
+	if a.Header.Get("Message-Id") == "" {
+		a.AddHeader("Message-ID", nntp.NewMessageID(v.PathIdentity))
+	}
+	if a.Header.Get("Date") == "" {
+		a.AddHeader("Date", time.Now().UTC().Format(time.RFC1123Z))
+	}
+	if a.Header.Get("Path") == "" {
+		a.AddHeader("Path", v.PathIdentity+"!.POSTED!not-for-mail")
+	}
+	if a.Header.Get("Injection-Info") == "" {
+		info := v.PathIdentity
+		if postingHost != "" {
+			info += "; posting-host=" + strconv.Quote(postingHost)
+		}
+		a.AddHeader("Injection-Info", info)
+	}
+}
+
+// Check verifies an article has all required headers with valid
+// values, returning an NNTPError with code 441 describing the first
+// problem found.
+func (v *Validator) Check(a *nntp.Article) error {
+	if problem := v.problem(a); problem != "" {
+		return &NNTPError{Code: 441, Msg: problem}
+	}
+	return nil
+}
+
+// problem describes the first thing wrong with an article, or returns
+// an empty string if it's fine.
+func (v *Validator) problem(a *nntp.Article) string {
+	for _, h := range requiredHeaders {
+		if strings.TrimSpace(a.Header.Get(h)) == "" {
+			return "Missing required header: " + h
+		}
+	}
+	for _, h := range uniqueHeaders {
+		if len(a.Header[h]) > 1 {
+			return "Duplicate header: " + h
+		}
+	}
+
+	if !nntp.ValidMessageID(a.Header.Get("Message-Id")) {
+		return "Invalid Message-ID header"
+	}
+	if _, err := mail.ParseDate(a.Header.Get("Date")); err != nil {
+		return "Invalid Date header"
+	}
+	if _, err := mail.ParseAddressList(a.Header.Get("From")); err != nil {
+		return "Invalid From header"
+	}
+
+	groups := strings.Split(a.Header.Get("Newsgroups"), ",")
+	for _, g := range groups {
+		if !validGroupName(strings.TrimSpace(g)) {
+			return "Invalid newsgroup name: " + g
+		}
+	}
+	if v.MaxCrossposts > 0 && len(groups) > v.MaxCrossposts {
+		return "Too many newsgroups"
+	}
+
+	return ""
+}
+
+// validGroupName reports whether name is a newsgroup-name as defined
+// by RFC 5536 section 3.1.4.
+func validGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, component := range strings.Split(name, ".") {
+		if component == "" {
+			return false
+		}
+		for _, r := range component {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+				r >= '0' && r <= '9', r == '+', r == '-', r == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// postingHost returns the host part of a remote address.
+func postingHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,74 @@
+package nntpserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-nntp"
+)
+
+func mustArticle(t *testing.T, s string) *nntp.Article {
+	a, err := nntp.ReadArticle(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("Error reading article: %v", err)
+	}
+	return a
+}
+
+func TestValidatorInject(t *testing.T) {
+	v := &Validator{PathIdentity: "news.example.com"}
+	a := mustArticle(t, "From: <nobody@example.com>\n"+
+		"Newsgroups: misc.test\nSubject: hi\n\nbody\n")
+
+	if err := v.Check(a); err == nil {
+		t.Fatalf("Expected article without Message-ID to fail")
+	}
+
+	v.Inject(a, "192.0.2.1")
+	if err := v.Check(a); err != nil {
+		t.Fatalf("Expected injected article to pass, got %v", err)
+	}
+	if !strings.HasSuffix(a.MessageID(), "@news.example.com>") {
+		t.Errorf("Unexpected message-id: %v", a.MessageID())
+	}
+	exp := `news.example.com; posting-host="192.0.2.1"`
+	if got := a.Header.Get("Injection-Info"); got != exp {
+		t.Errorf("Expected Injection-Info %q, got %q", exp, got)
+	}
+	if !strings.Contains(string(a.RawHeader), "Path: news.example.com!") {
+		t.Errorf("Injected headers missing from raw headers: %q", a.RawHeader)
+	}
+}
+
+func TestValidatorCheck(t *testing.T) {
+	const valid = "From: <nobody@example.com>\n" +
+		"Subject: hi\n" +
+		"Message-ID: <1@example.com>\n" +
+		"Date: Mon, 02 Jan 2006 15:04:05 -0700\n" +
+		"Path: not-for-mail\n"
+
+	tests := []struct {
+		in      string
+		problem string
+	}{
+		{valid + "Newsgroups: misc.test,alt.test\n", ""},
+		{valid + "Newsgroups: misc.test\nSubject: again\n", "Duplicate header: Subject"},
+		{valid + "Newsgroups: misc..test\n", "Invalid newsgroup name: misc..test"},
+		{valid + "Newsgroups: a,b,c\n", "Too many newsgroups"},
+		{strings.Replace(valid, "<1@example.com>", "1@example.com", 1) +
+			"Newsgroups: misc.test\n", "Invalid Message-ID header"},
+	}
+
+	v := &Validator{PathIdentity: "news.example.com", MaxCrossposts: 2}
+	for _, test := range tests {
+		err := v.Check(mustArticle(t, test.in+"\nbody\n"))
+		switch {
+		case test.problem == "" && err != nil:
+			t.Errorf("Expected %q to pass, got %v", test.in, err)
+		case test.problem != "" && err == nil:
+			t.Errorf("Expected %q to fail", test.in)
+		case err != nil && err.Error() != "441 "+test.problem:
+			t.Errorf("Expected 441 %v, got %v", test.problem, err)
+		}
+	}
+}
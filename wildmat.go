@@ -0,0 +1,48 @@
+package nntp
+
+import "strings"
+
+// MatchWildmat reports whether name matches the wildmat pattern, as
+// defined by RFC 3977 section 4.  A wildmat is a comma-separated list
+// of patterns using "*" and "?", each optionally negated with "!".
+// The last pattern that matches decides the result.
+func MatchWildmat(wildmat, name string) bool {
+	rv := false
+	for _, p := range strings.Split(wildmat, ",") {
+		p = strings.TrimSpace(p)
+		negate := strings.HasPrefix(p, "!")
+		if negate {
+			p = p[1:]
+		}
+		if p != "" && matchPattern(p, name) {
+			rv = !negate
+		}
+	}
+	return rv
+}
+
+// matchPattern matches a single pattern without commas or "!".
+func matchPattern(p, s string) bool {
+	// Where to resume after the last "*".
+	star, next := -1, 0
+	i, j := 0, 0
+	for j < len(s) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, next = i, j
+			i++
+		case i < len(p) && (p[i] == '?' || p[i] == s[j]):
+			i++
+			j++
+		case star != -1:
+			next++
+			i, j = star+1, next
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
@@ -0,0 +1,30 @@
+package nntp
+
+import "testing"
+
+func TestMatchWildmat(t *testing.T) {
+	tests := []struct {
+		wildmat, name string
+		exp           bool
+	}{
+		{"*", "comp.lang.go", true},
+		{"comp.*", "comp.lang.go", true},
+		{"comp.*", "alt.comp", false},
+		{"comp.lang.?o", "comp.lang.go", true},
+		{"comp.lang.?o", "comp.lang.goo", false},
+		{"*.go", "comp.lang.go", true},
+		{"c*l*g", "comp.lang.go.misc.lang", true},
+		{"comp.*,!comp.binaries.*", "comp.binaries.misc", false},
+		{"comp.*,!comp.binaries.*", "comp.lang.go", true},
+		{"!comp.binaries.*,comp.*", "comp.binaries.misc", true},
+		{"", "comp.lang.go", false},
+		{"misc.test", "misc.test", true},
+		{"misc.test", "misc.testing", false},
+	}
+	for _, test := range tests {
+		if got := MatchWildmat(test.wildmat, test.name); got != test.exp {
+			t.Errorf("MatchWildmat(%q, %q) = %v, want %v",
+				test.wildmat, test.name, got, test.exp)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedYEnc is returned when a compressed overview block isn't
+// valid yEnc.
+var ErrMalformedYEnc = errors.New("malformed yEnc data")
+
+const yencLineLength = 128
+
+// EncodeXZVer writes overview data the way an XZVER response carries
+// it: DEFLATE-compressed, then yEnc-encoded into lines.  The result
+// still needs dot-stuffing and a terminator, so w is normally a
+// DotWriter.
+func EncodeXZVer(w io.Writer, overview []byte) error {
+	var z bytes.Buffer
+	fw, _ := flate.NewWriter(&z, flate.BestCompression)
+	fw.Write(overview)
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	data := z.Bytes()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "=ybegin line=%d size=%d name=xzver\r\n",
+		yencLineLength, len(data))
+	col := 0
+	for _, b := range data {
+		c := b + 42
+		switch c {
+		case 0, '\n', '\r', '=':
+			bw.WriteByte('=')
+			c += 64
+			col++
+		}
+		bw.WriteByte(c)
+		col++
+		if col >= yencLineLength {
+			bw.WriteString("\r\n")
+			col = 0
+		}
+	}
+	if col > 0 {
+		bw.WriteString("\r\n")
+	}
+	fmt.Fprintf(bw, "=yend size=%d crc32=%08x\r\n",
+		len(data), crc32.ChecksumIEEE(data))
+	return bw.Flush()
+}
+
+// DecodeXZVer reverses EncodeXZVer, returning the overview data from
+// the (already dot-decoded) body of an XZVER response.
+func DecodeXZVer(r io.Reader) ([]byte, error) {
+	data, err := decodeYEnc(r)
+	if err != nil {
+		return nil, err
+	}
+	fr := flate.NewReader(bytes.NewReader(data))
+	defer fr.Close()
+	return io.ReadAll(fr)
+}
+
+func decodeYEnc(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var rv []byte
+	begun, ended := false, false
+	var trailer string
+	for !ended {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil, ErrMalformedYEnc
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "=ybegin "):
+			begun = true
+		case !begun:
+			// Anything before the header is ignored.
+		case strings.HasPrefix(line, "=ypart "):
+		case strings.HasPrefix(line, "=yend"):
+			trailer, ended = line, true
+		default:
+			for i := 0; i < len(line); i++ {
+				c := line[i]
+				if c == '=' {
+					i++
+					if i == len(line) {
+						return nil, ErrMalformedYEnc
+					}
+					c = line[i] - 64
+				}
+				rv = append(rv, c-42)
+			}
+		}
+	}
+
+	for _, f := range strings.Fields(trailer)[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		k, v := kv[0], kv[1]
+		switch k {
+		case "size":
+			if n, err := strconv.Atoi(v); err != nil || n != len(rv) {
+				return nil, ErrMalformedYEnc
+			}
+		case "crc32", "pcrc32":
+			n, err := strconv.ParseUint(v, 16, 32)
+			if err != nil || uint32(n) != crc32.ChecksumIEEE(rv) {
+				return nil, ErrMalformedYEnc
+			}
+		}
+	}
+	return rv, nil
+}
@@ -0,0 +1,49 @@
+package nntp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestXZVerRoundTrip(t *testing.T) {
+	var overview bytes.Buffer
+	for i := 1; i <= 500; i++ {
+		fmt.Fprintf(&overview, "%d\tSubject %d\tsomeone@example.com\t"+
+			"Mon, 2 Jan 2006 15:04:05 -0700\t<%d@example.com>\t\t%d\t%d\r\n",
+			i, i, i, 1000+i, 10+i%7)
+	}
+	var enc bytes.Buffer
+	if err := EncodeXZVer(&enc, overview.Bytes()); err != nil {
+		t.Fatalf("Error encoding: %v", err)
+	}
+	for _, line := range strings.Split(enc.String(), "\r\n") {
+		if strings.ContainsAny(line, "\r\n\x00") {
+			t.Fatalf("Encoded line contains a critical character: %q", line)
+		}
+	}
+	got, err := DecodeXZVer(&enc)
+	if err != nil {
+		t.Fatalf("Error decoding: %v", err)
+	}
+	if !bytes.Equal(got, overview.Bytes()) {
+		t.Fatalf("Round trip mismatch:\n%q\nvs\n%q", got, overview.Bytes())
+	}
+}
+
+func TestXZVerCorrupt(t *testing.T) {
+	var enc bytes.Buffer
+	if err := EncodeXZVer(&enc, []byte("1\tx\r\n")); err != nil {
+		t.Fatalf("Error encoding: %v", err)
+	}
+	b := enc.Bytes()
+	i := bytes.IndexByte(b, '\n') + 1
+	b[i]++
+	if _, err := DecodeXZVer(bytes.NewReader(b)); err != ErrMalformedYEnc {
+		t.Fatalf("Expected ErrMalformedYEnc, got %v", err)
+	}
+	if _, err := DecodeXZVer(strings.NewReader("=ybegin size=3\r\nabc\r\n")); err != ErrMalformedYEnc {
+		t.Fatalf("Expected ErrMalformedYEnc for missing trailer, got %v", err)
+	}
+}